@@ -0,0 +1,297 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"evm-signer/base"
+	"evm-signer/pkg/ethutils"
+	"evm-signer/service"
+	"evm-signer/service/account"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	keystoreOut    string
+	passFile       string
+	newPassFile    string
+	importKeyFile  string
+	importMnemonic bool
+	importIndex    int
+	importPath     string
+	importSeedFile string
+	listIndex      string
+	listUnlock     bool
+)
+
+func init() {
+	accountNewCmd.Flags().StringVar(&keystoreOut, "out", "conf/keystore/", "keystore file, or a directory to create it in")
+	accountNewCmd.Flags().StringVar(&passFile, "pass-file", "", "read the passphrase from a file instead of the prompt")
+
+	accountImportCmd.Flags().StringVar(&keystoreOut, "out", "conf/keystore/", "keystore file, or a directory to create it in")
+	accountImportCmd.Flags().StringVar(&passFile, "pass-file", "", "read the keystore passphrase from a file instead of the prompt")
+	accountImportCmd.Flags().StringVar(&importKeyFile, "key-file", "", "read the private key or the mnemonic from a file, - for stdin, instead of the prompt")
+	accountImportCmd.Flags().BoolVar(&importMnemonic, "mnemonic", false, "import the account of a mnemonic instead of a private key")
+	accountImportCmd.Flags().IntVar(&importIndex, "index", 0, "the account index of the mnemonic")
+	accountImportCmd.Flags().StringVar(&importPath, "path", ethutils.DefaultPath, "the derivation path of the mnemonic, {index} is replaced by --index")
+	accountImportCmd.Flags().StringVar(&importSeedFile, "seed-pass-file", "", "read the BIP-39 passphrase of the mnemonic from a file")
+
+	accountListCmd.Flags().StringVar(&listIndex, "index", "", "derive this index range of the PlainMnemonic and EncryptedMnemonic sources instead of the configured ones, eg. 0-9,256")
+	accountListCmd.Flags().BoolVar(&listUnlock, "unlock", false, "decrypt the keys, needed for the addresses of an EncryptedMnemonic")
+
+	accountPasswdCmd.Flags().StringVar(&passFile, "pass-file", "", "read the current passphrase from a file instead of the prompt")
+	accountPasswdCmd.Flags().StringVar(&newPassFile, "new-pass-file", "", "read the new passphrase from a file instead of the prompt")
+
+	accountCmd.AddCommand(accountNewCmd)
+	accountCmd.AddCommand(accountImportCmd)
+	accountCmd.AddCommand(accountListCmd)
+	accountCmd.AddCommand(accountPasswdCmd)
+}
+
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "create, import and list accounts without starting the signer.",
+}
+
+var accountNewCmd = &cobra.Command{
+	Use:     "new",
+	Short:   "create a new account in an encrypted keystore",
+	Example: "./signer account new --out conf/keystore/",
+	Run: func(cmd *cobra.Command, args []string) {
+		priKey, err := crypto.GenerateKey()
+		if err != nil {
+			exitf("failed to generate key: %v", err)
+		}
+		writeKeystore(priKey)
+	},
+}
+
+var accountImportCmd = &cobra.Command{
+	Use:     "import",
+	Short:   "import a private key or an account of a mnemonic into an encrypted keystore",
+	Example: "./signer account import --out conf/keystore/\n./signer account import --mnemonic --index 3 --key-file mnemonic.txt",
+	Run: func(cmd *cobra.Command, args []string) {
+		text := "Private key: "
+		if importMnemonic {
+			text = "Mnemonic: "
+		}
+		secret := readSecret(importKeyFile, text)
+
+		var _account *ethutils.Account
+		if importMnemonic {
+			if importIndex < 0 {
+				exitf("index must be >= 0")
+			}
+			mnemonic := strings.Join(strings.Fields(secret), " ")
+			if err := ethutils.ValidateMnemonic(mnemonic); err != nil {
+				exitf("%s", err)
+			}
+			if err := ethutils.ValidatePath(importPath); err != nil {
+				exitf("%s", err)
+			}
+			seedPass := ""
+			if importSeedFile != "" {
+				data, err := os.ReadFile(importSeedFile)
+				if err != nil {
+					exitf("%s", err)
+				}
+				seedPass = strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+			}
+			var err error
+			if _account, err = ethutils.DeriveAccount(mnemonic, seedPass, importPath, importIndex); err != nil {
+				exitf("%s", err)
+			}
+		} else {
+			_account = ethutils.GetAccountFromPStr(strings.TrimSpace(secret))
+		}
+		if _account == nil {
+			exitf("invalid private key or mnemonic")
+		}
+		writeKeystore(_account.PrivateKey)
+	},
+}
+
+var accountListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "list the addresses of the configured account sources",
+	Example: "./signer account list\n./signer account list --index 0-99 --unlock",
+	Run: func(cmd *cobra.Command, args []string) {
+		vr, err := base.LoadConfig()
+		if err != nil {
+			exitf("%s", err)
+		}
+		logger = base.GetLogger("signer").Sugar()
+		service.SetLogger(logger)
+
+		sources, err := service.GetAccountSources(&base.SignerConfig{Config: vr})
+		if err != nil {
+			exitf("%s", err)
+		}
+		aliases := make(map[string][]string)
+		mnemonics := 0
+		for _, source := range sources {
+			for alias, index := range source.Aliases {
+				ref := fmt.Sprintf("%s:%d", source.Name, index)
+				aliases[ref] = append(aliases[ref], alias)
+			}
+			accountTy, _ := source.Params["type"].(string)
+			if listIndex != "" && (accountTy == string(account.PlainMnemonicTy) || accountTy == string(account.EncryptedMnemonicTy)) {
+				// the listed indices are derived even for a lazy source
+				source.Params["index"] = listIndex
+				source.Params["lazy"] = false
+				mnemonics++
+			}
+		}
+		if listIndex != "" && mnemonics == 0 {
+			exitf("--index needs a PlainMnemonic or EncryptedMnemonic account source")
+		}
+		keyring, err := account.NewKeyring(sources...)
+		if err != nil {
+			exitf("%s", err)
+		}
+
+		var unlocked map[*account.Key]*account.Unlocked
+		if listUnlock {
+			if unlocked, err = keyring.UnlockAll(); err != nil {
+				exitf("%s", err)
+			}
+		} else {
+			keyring.Forget()
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "SOURCE\tINDEX\tADDRESS\tTYPE\tKEY\tALIASES")
+		row := func(name string, index int64, address, ty, key string) {
+			_aliases := aliases[fmt.Sprintf("%s:%d", name, index)]
+			sort.Strings(_aliases)
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", name, index, address, ty, key, strings.Join(_aliases, ","))
+		}
+		order := make(map[string]int)
+		for position, source := range sources {
+			order[source.Name] = position
+		}
+		sort.Slice(keyring.Accounts, func(i, j int) bool {
+			a, b := keyring.Accounts[i], keyring.Accounts[j]
+			if a.Name != b.Name {
+				return order[a.Name] < order[b.Name]
+			}
+			return a.Index < b.Index
+		})
+		for _, _account := range keyring.Accounts {
+			row(_account.Name, _account.Index, _account.Address.Hex(), _account.Source, "-")
+		}
+		lazy := func(name, ty, key string) {
+			indexRange := "-"
+			for _, source := range sources {
+				if source.Name == name {
+					indexRange, _ = source.Params["index"].(string)
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", name, indexRange, "derived on demand, use --index", ty, key)
+		}
+		for _, wallet := range keyring.Wallets {
+			lazy(wallet.Name, string(wallet.Type), "-")
+		}
+		for _, key := range keyring.Keys {
+			if listUnlock {
+				if unlocked[key].Wallet != nil {
+					lazy(key.Name, string(key.Type), key.Source)
+					continue
+				}
+				accounts := unlocked[key].Accounts
+				sort.Slice(accounts, func(i, j int) bool {
+					return accounts[i].Index < accounts[j].Index
+				})
+				for _, _account := range accounts {
+					row(key.Name, _account.Index, _account.Address.Hex(), _account.Source, key.Source)
+				}
+				continue
+			}
+			if len(key.Addresses) == 0 {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", key.Name, "-", "locked, use --unlock", key.Type, key.Source)
+			}
+			for _, address := range key.Addresses {
+				row(key.Name, key.Index, address.Hex(), string(key.Type), key.Source)
+			}
+		}
+		_ = w.Flush()
+	},
+}
+
+var accountPasswdCmd = &cobra.Command{
+	Use:     "change-password <keystore>",
+	Short:   "change the passphrase of a keystore or an encrypted mnemonic file",
+	Example: "./signer account change-password conf/keystore/UTC--...",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		oldPass := readPassphrase(passFile, "please enter the current password", false)
+		newPass := readPassphrase(newPassFile, "please enter the new password", true)
+		if err := account.ChangePassword(args[0], oldPass, newPass); err != nil {
+			exitf("change password of %s: %s", args[0], err)
+		}
+		fmt.Printf("password of %s changed\n", args[0])
+	},
+}
+
+// writeKeystore encrypts the key with a new passphrase into the --out keystore
+func writeKeystore(priKey *ecdsa.PrivateKey) {
+	pass := readPassphrase(passFile, "please enter a password for the new keystore", true)
+	path, address, err := account.WriteKeystore(keystoreOut, priKey, pass)
+	if err != nil {
+		exitf("write keystore: %s", err)
+	}
+	fmt.Printf("address: %s\nkeystore: %s\n", address.Hex(), path)
+}
+
+// readPassphrase reads a passphrase from the file or the prompt, an empty passphrase exits
+func readPassphrase(file, text string, confirmation bool) string {
+	var pass string
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			exitf("%s", err)
+		}
+		pass = strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	} else {
+		pass = account.PromptPassphrase(text, confirmation)
+	}
+	if pass == "" {
+		exitf("passphrase is empty")
+	}
+	return pass
+}
+
+// readSecret reads a private key or a mnemonic from the file, - for stdin, or the prompt without echoing it
+func readSecret(file, text string) string {
+	if file == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			exitf("%s", err)
+		}
+		return strings.TrimSpace(string(data))
+	}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			exitf("%s", err)
+		}
+		return strings.TrimSpace(string(data))
+	}
+	secret, err := prompt.Stdin.PromptPassword(text)
+	if err != nil {
+		exitf("failed to read: %v", err)
+	}
+	return secret
+}
+
+func exitf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ERROR "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"evm-signer/types"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	signerURL       string
+	addressSource   string
+	addressFormat   string
+	addressPageSize int
+)
+
+func init() {
+	addressesCmd.PersistentFlags().StringVar(&signerURL, "url", "http://127.0.0.1:8080", "url of the running signer")
+	addressesCmd.PersistentFlags().StringVar(&addressFormat, "format", "table", "table, csv or json")
+	addressListCmd.Flags().StringVar(&addressSource, "source", "", "only the addresses of this account source")
+	addressListCmd.Flags().IntVar(&addressPageSize, "page-size", 1000, "addresses fetched per request")
+	addressesCmd.AddCommand(addressListCmd)
+	addressesCmd.AddCommand(xpubListCmd)
+}
+
+var addressesCmd = &cobra.Command{
+	Use:   "addresses",
+	Short: "export the addresses, public keys and xpubs of a running signer for watch-only wallets.",
+}
+
+var addressListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "list every loaded address with its public keys",
+	Example: "./signer addresses list --source deposits --format csv > deposits.csv",
+	Run: func(cmd *cobra.Command, args []string) {
+		var addresses []*types.AddressInfo
+		for page := 1; ; page++ {
+			query := url.Values{}
+			query.Set("page", strconv.Itoa(page))
+			query.Set("page_size", strconv.Itoa(addressPageSize))
+			if addressSource != "" {
+				query.Set("source", addressSource)
+			}
+			result := &types.AddressPage{}
+			if err := signerGet("/v1/addresses?"+query.Encode(), result); err != nil {
+				exitf("%s", err)
+			}
+			addresses = append(addresses, result.Addresses...)
+			if len(result.Addresses) == 0 || len(addresses) >= result.Total {
+				break
+			}
+		}
+
+		switch addressFormat {
+		case "json":
+			printJSON(addresses)
+		case "csv":
+			w := csv.NewWriter(os.Stdout)
+			_ = w.Write([]string{"source", "index", "address", "aliases", "type", "path",
+				"public_key", "public_key_uncompressed", "xpub", "locked"})
+			for _, address := range addresses {
+				_ = w.Write([]string{address.Source, strconv.FormatInt(address.Index, 10), address.Address,
+					strings.Join(address.Aliases, ","), address.Type, address.Path, address.PublicKey,
+					address.PublicKeyUncompressed, address.XPub, strconv.FormatBool(address.Locked)})
+			}
+			w.Flush()
+		default:
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "SOURCE\tINDEX\tADDRESS\tALIASES\tPATH\tPUBLIC KEY")
+			for _, address := range addresses {
+				publicKey := address.PublicKey
+				if address.Locked {
+					publicKey = "locked"
+				}
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", address.Source, address.Index, address.Address,
+					strings.Join(address.Aliases, ","), orDash(address.Path), orDash(publicKey))
+			}
+			_ = w.Flush()
+		}
+	},
+}
+
+var xpubListCmd = &cobra.Command{
+	Use:     "xpubs",
+	Short:   "list the xpub above the {index} element of every mnemonic source with its path",
+	Example: "./signer addresses xpubs",
+	Run: func(cmd *cobra.Command, args []string) {
+		var xpubs []*types.XPubInfo
+		if err := signerGet("/v1/addresses/xpubs", &xpubs); err != nil {
+			exitf("%s", err)
+		}
+
+		switch addressFormat {
+		case "json":
+			printJSON(xpubs)
+		case "csv":
+			w := csv.NewWriter(os.Stdout)
+			_ = w.Write([]string{"source", "type", "path", "index", "lazy", "xpub", "xpub_path", "locked", "hardened"})
+			for _, xpub := range xpubs {
+				_ = w.Write([]string{xpub.Source, xpub.Type, xpub.Path, xpub.Index, strconv.FormatBool(xpub.Lazy),
+					xpub.XPub, xpub.XPubPath, strconv.FormatBool(xpub.Locked), strconv.FormatBool(xpub.Hardened)})
+			}
+			w.Flush()
+		default:
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "SOURCE\tTYPE\tPATH\tINDEX\tXPUB PATH\tXPUB")
+			for _, xpub := range xpubs {
+				value := xpub.XPub
+				if xpub.Hardened {
+					value = "none, the {index} element is hardened"
+				} else if xpub.Locked {
+					value = "locked, use signer unlock"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", xpub.Source, xpub.Type, xpub.Path, xpub.Index, xpub.XPubPath, value)
+			}
+			_ = w.Flush()
+		}
+	},
+}
+
+// signerGet calls a client endpoint of a running signer and decodes the data of its response, a rate limited
+// request is retried after the time the signer asks for
+func signerGet(path string, data interface{}) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Get(strings.TrimRight(signerURL, "/") + path)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < 10 {
+			wait, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			time.Sleep(time.Duration(wait+1) * time.Second)
+			continue
+		}
+		out := struct {
+			Code int             `json:"code"`
+			Msg  string          `json:"msg"`
+			Data json.RawMessage `json:"data"`
+		}{}
+		if err = json.Unmarshal(body, &out); err != nil {
+			return fmt.Errorf("signer responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		if resp.StatusCode != http.StatusOK || out.Code != 0 {
+			return fmt.Errorf("signer responded %s, code %d: %s", resp.Status, out.Code, out.Msg)
+		}
+		return json.Unmarshal(out.Data, data)
+	}
+}
+
+func printJSON(v interface{}) {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(data))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	adminURL   string
+	adminToken string
+)
+
+// adminRequest calls the admin api of a running signer and prints the response
+func adminRequest(method, path string, body io.Reader) error {
+	if adminToken == "" {
+		adminToken = os.Getenv("SIGNER_ADMIN_TOKEN")
+	}
+	if adminToken == "" {
+		return fmt.Errorf("admin token is required, use --token or SIGNER_ADMIN_TOKEN")
+	}
+
+	req, err := http.NewRequest(method, strings.TrimRight(adminURL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var out interface{}
+	if err = json.Unmarshal(data, &out); err == nil {
+		data, _ = json.MarshalIndent(out, "", "  ")
+	}
+	fmt.Println(string(data))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("signer responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var approvalStatus string
+
+func init() {
+	approvalCmd.PersistentFlags().StringVar(&adminURL, "url", "http://127.0.0.1:8080", "url of the running signer")
+	approvalCmd.PersistentFlags().StringVar(&adminToken, "token", "", "admin token, default $SIGNER_ADMIN_TOKEN")
+	approvalListCmd.Flags().StringVar(&approvalStatus, "status", "pending", "pending, approved, signed, failed, rejected, expired or empty for all")
+	approvalCmd.AddCommand(approvalListCmd)
+	approvalCmd.AddCommand(approveCmd)
+	approvalCmd.AddCommand(rejectCmd)
+}
+
+var approvalCmd = &cobra.Command{
+	Use:   "approvals",
+	Short: "list, approve and reject signing requests waiting for approval.",
+}
+
+var approvalListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "list approval requests",
+	Example: "./signer approvals list --status pending",
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "/admin/v1/approvals?status=" + url.QueryEscape(approvalStatus)
+		if err := adminRequest(http.MethodGet, path, nil); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var approveCmd = &cobra.Command{
+	Use:     "approve <request_id>",
+	Short:   "approve a signing request",
+	Example: "./signer approvals approve 2f1c...",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := adminRequest(http.MethodPost, "/admin/v1/approvals/"+url.PathEscape(args[0])+"/approve", nil); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var rejectCmd = &cobra.Command{
+	Use:     "reject <request_id>",
+	Short:   "reject a signing request",
+	Example: "./signer approvals reject 2f1c...",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := adminRequest(http.MethodPost, "/admin/v1/approvals/"+url.PathEscape(args[0])+"/reject", nil); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
@@ -5,89 +5,127 @@ import (
 	"fmt"
 	"github.com/spf13/viper"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
 type SignerConfig struct {
-	Config *viper.Viper
-	Rule   []byte
+	Config    *viper.Viper
+	RulePath  string      // the rule file or directory the rules were loaded from
+	RuleDir   string      // the directory of the rule files
+	RuleFiles []*RuleFile // in merge order
 }
 
-var (
-	scfg        *SignerConfig
-	logger      *logging.SugaredLogger
-	ServiceName = "signer"
-)
-
-func init() {
-	scfg = new(SignerConfig)
-	var err error
-	scfg.Config, err = initConfigFromLocalFile("config", "yaml")
-	if err != nil {
-		fmt.Println("missing config.yaml file")
-		os.Exit(1)
-	}
+var ServiceName = "signer"
 
-	logger = GetLogger("parse").Sugar()
+// RuleFile a rule file and its content
+type RuleFile struct {
+	Path string
+	Data []byte
 }
 
-func initConfigFromLocalFile(fileName, fileType string) (*viper.Viper, error) {
-	vr := viper.New()
-	vr.SetConfigName(fileName)
-	vr.SetConfigType(fileType)
-	vr.AddConfigPath("./conf")
-	vr.AddConfigPath("../conf")
-	vr.AddConfigPath("../../conf")
-	err := vr.ReadInConfig()
+// GetSignerConfig loads the config and the rules of rulePath, a JSON or YAML file or a directory of them.
+// A bare file name is looked up in the config dir and the conf dirs, other paths are used as they are
+func GetSignerConfig(rulePath string) (*SignerConfig, error) {
+	vr, err := LoadConfig()
 	if err != nil {
 		return nil, err
 	}
-	return vr, err
-}
-
-func GetSignerConfig(ruleName string) *SignerConfig {
-	logger.Infof("ruleName: %s", ruleName)
+	logger := GetLogger("parse").Sugar()
+	logger.Infof("ruleName: %s", rulePath)
 
-	ruleFileArr := strings.Split(ruleName, ".")
-	if len(ruleFileArr) < 2 {
-		fmt.Println("invalidate file name, eg. rule.json")
-		os.Exit(1)
+	path, err := resolveRulePath(rulePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find rule path %s: %s", rulePath, err)
 	}
 
-	if ruleFileArr[1] != "json" {
-		logger.Fatalf(".%s unsupported file type, only JSON rules are supported", ruleFileArr[1])
-		return nil
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rule path %s: %s", path, err)
 	}
 
-	// Try to load rule file from conf directories
-	confPaths := []string{"./conf", "../conf", "../../conf"}
-	var rule []byte
-	var err error
-	var loadedPath string
+	var files []string
+	ruleDir := filepath.Dir(path)
+	if info.IsDir() {
+		ruleDir = path
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rule directory %s: %s", path, err)
+		}
+		// ReadDir sorts by file name, so the rules merge in the same order everywhere
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !isRuleFile(entry.Name()) {
+				continue
+			}
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no .json, .yaml or .yml rule files in %s", path)
+		}
+	} else {
+		if !isRuleFile(path) {
+			return nil, fmt.Errorf("%s unsupported file type, rules are .json, .yaml or .yml files", path)
+		}
+		files = []string{path}
+	}
 
-	for _, confPath := range confPaths {
-		rulePath := confPath + "/" + ruleName
-		rule, err = os.ReadFile(rulePath)
-		if err == nil {
-			loadedPath = rulePath
-			break
+	ruleFiles := make([]*RuleFile, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load rule file %s: %s", file, err)
 		}
+		logger.Infof("loaded rule file from: %s", file)
+		ruleFiles = append(ruleFiles, &RuleFile{Path: file, Data: data})
 	}
 
-	if err != nil {
-		logger.Fatalf("failed to load rule file %s from conf directories: %s", ruleName, err.Error())
+	return &SignerConfig{
+		Config:    vr,
+		RulePath:  path,
+		RuleDir:   ruleDir,
+		RuleFiles: ruleFiles,
+	}, nil
+}
+
+// resolveRulePath a bare name is searched in the config dir and the conf dirs, then in the working directory
+func resolveRulePath(rulePath string) (string, error) {
+	if rulePath == "" {
+		return "", fmt.Errorf("rule path is empty")
+	}
+	if filepath.IsAbs(rulePath) || strings.ContainsRune(rulePath, filepath.Separator) || strings.Contains(rulePath, "/") {
+		return filepath.Clean(rulePath), nil
 	}
 
-	logger.Infof("loaded rule file from: %s", loadedPath)
-	scfg.Rule = rule
-	return scfg
+	dirs := confPaths
+	configLock.Lock()
+	if configDir != "" {
+		dirs = append([]string{configDir}, confPaths...)
+	}
+	configLock.Unlock()
+	for _, dir := range dirs {
+		path := filepath.Join(dir, rulePath)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	if _, err := os.Stat(rulePath); err != nil {
+		return "", fmt.Errorf("not found in %s or the working directory", strings.Join(dirs, ", "))
+	}
+	return rulePath, nil
 }
 
-func GetConfig() *SignerConfig {
-	return scfg
+func isRuleFile(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
 }
 
+// GetLogger a logger configured by the log section of the loaded config, the defaults before it is loaded
 func GetLogger(module string) *logging.Logger {
-	logConfig := logging.GetLogConfig(GetConfig().Config)
-	return logging.GetLogger(ServiceName, module, logConfig)
+	configLock.Lock()
+	vr := config
+	configLock.Unlock()
+	return logging.GetLogger(ServiceName, module, logging.GetLogConfig(vr))
 }
@@ -0,0 +1,190 @@
+package base
+
+import (
+	"bytes"
+	"evm-signer/pkg/logging"
+	"fmt"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	EnvPrefix     = "SIGNER_"
+	ConfigFileEnv = EnvPrefix + "CONFIG" // the config file when --config isn't given
+)
+
+var (
+	configLock sync.Mutex
+	configFile string
+	config     *viper.Viper
+	configDir  string
+
+	// the default search path of config.yaml and of bare rule file names
+	confPaths = []string{"./conf", "../conf", "../../conf"}
+)
+
+// SetConfigFile sets the config file LoadConfig reads, empty means $SIGNER_CONFIG or config.yaml in the conf dirs.
+// It drops a loaded config
+func SetConfigFile(file string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	configFile = file
+	config = nil
+	configDir = ""
+}
+
+// LoadConfig loads the config file once, the SIGNER_* environment variables override its keys
+func LoadConfig() (*viper.Viper, error) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	if config != nil {
+		return config, nil
+	}
+
+	vr := viper.New()
+	file := configFile
+	if file == "" {
+		file = os.Getenv(ConfigFileEnv)
+	}
+	if file != "" {
+		vr.SetConfigFile(file)
+		if ext := strings.TrimPrefix(filepath.Ext(file), "."); ext != "yaml" && ext != "yml" {
+			vr.SetConfigType("yaml")
+		}
+	} else {
+		vr.SetConfigName("config")
+		vr.SetConfigType("yaml")
+		for _, confPath := range confPaths {
+			vr.AddConfigPath(confPath)
+		}
+	}
+	if err := vr.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			return nil, fmt.Errorf("config.yaml not found in %s, use --config or %s", strings.Join(confPaths, ", "), ConfigFileEnv)
+		}
+		return nil, fmt.Errorf("read config %s error: %s", file, err)
+	}
+	ignored, err := applyEnv(vr, os.Environ())
+	if err != nil {
+		return nil, err
+	}
+
+	config = vr
+	configDir = filepath.Dir(vr.ConfigFileUsed())
+	logger := logging.GetLogger(ServiceName, "config", logging.GetLogConfig(vr)).Sugar()
+	for _, name := range ignored {
+		logger.Warnf("%s names no key of the config file and is ignored, use __ between the levels to add a key", name)
+	}
+	return config, nil
+}
+
+// applyEnv merges the SIGNER_* variables into the config. A variable overrides the key it names with the dots
+// replaced by _, eg. SIGNER_LOG_LEVEL for log.level or SIGNER_CHAINS_ETHEREUM_MAX_FEE for chains.ethereum.max_fee.
+// A name with __ names the levels itself, eg. SIGNER_METRICS__LISTEN for metrics.listen, which also adds keys
+// missing from the file. A name which matches several keys, like a_b and a.b, is an error.
+// Values starting with [ or { are parsed as YAML lists and maps.
+// It returns the variables under a config section which name no key and were ignored
+func applyEnv(vr *viper.Viper, environ []string) ([]string, error) {
+	// the leaf keys and their sections, so a whole map or list can be replaced too
+	known := make(map[string][]string)
+	for _, key := range vr.AllKeys() {
+		parts := strings.Split(key, ".")
+		for i := range parts {
+			section := strings.Join(parts[:i+1], ".")
+			name := envName(section)
+			if !containsString(known[name], section) {
+				known[name] = append(known[name], section)
+			}
+		}
+	}
+
+	settings := vr.AllSettings()
+	overridden := false
+	var ignored []string
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) || name == ConfigFileEnv {
+			continue
+		}
+
+		var key string
+		if strings.Contains(name, "__") {
+			key = strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, EnvPrefix), "__", "."))
+		} else {
+			keys := known[name]
+			if len(keys) > 1 {
+				sort.Strings(keys)
+				return nil, fmt.Errorf("%s is ambiguous, it names %s, use __ between the levels",
+					name, strings.Join(keys, " and "))
+			}
+			if len(keys) == 0 {
+				if underSection(known, name) {
+					ignored = append(ignored, name)
+				}
+				continue
+			}
+			key = keys[0]
+		}
+
+		var parsed interface{} = value
+		if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") {
+			if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+				return nil, fmt.Errorf("invalid %s: %s", name, err)
+			}
+		}
+		setPath(settings, strings.Split(key, "."), parsed)
+		overridden = true
+	}
+	sort.Strings(ignored)
+	if !overridden {
+		return ignored, nil
+	}
+
+	// MergeConfigMap skips values whose type differs from the file, so the whole config is replaced
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	return ignored, vr.ReadConfig(bytes.NewReader(data))
+}
+
+// underSection whether the variable starts with the name of a config section, eg. SIGNER_LOG_FORMAT
+func underSection(known map[string][]string, name string) bool {
+	for section := range known {
+		if strings.HasPrefix(name, section+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// envName the environment variable of a config key
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+func setPath(m map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[key] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
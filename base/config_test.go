@@ -0,0 +1,114 @@
+package base
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const testConfig = `
+log:
+  level: info
+chains:
+  ethereum:
+    chain_id: 1
+    max_fee: "100"
+admin:
+  tokens:
+    alice: a
+a_b: 1
+a:
+  b: 2
+`
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		environ []string
+		want    map[string]interface{} // key -> value after the overrides
+		ignored []string
+		wantErr string
+	}{
+		{
+			name:    "leaf",
+			environ: []string{"SIGNER_LOG_LEVEL=debug"},
+			want:    map[string]interface{}{"log.level": "debug"},
+		},
+		{
+			name:    "nested key with underscores",
+			environ: []string{"SIGNER_CHAINS_ETHEREUM_MAX_FEE=5"},
+			want:    map[string]interface{}{"chains.ethereum.max_fee": "5", "chains.ethereum.chain_id": 1},
+		},
+		{
+			name:    "levels named with __",
+			environ: []string{"SIGNER_CHAINS__ETHEREUM__MAX_FEE=6"},
+			want:    map[string]interface{}{"chains.ethereum.max_fee": "6"},
+		},
+		{
+			name:    "key missing from the file",
+			environ: []string{"SIGNER_METRICS__LISTEN=127.0.0.1:9100"},
+			want:    map[string]interface{}{"metrics.listen": "127.0.0.1:9100"},
+		},
+		{
+			name:    "yaml map replaces the section",
+			environ: []string{"SIGNER_ADMIN_TOKENS={ops: change-me}"},
+			want:    map[string]interface{}{"admin.tokens.ops": "change-me", "admin.tokens.alice": nil},
+		},
+		{
+			name:    "ambiguous name",
+			environ: []string{"SIGNER_A_B=3"},
+			wantErr: "SIGNER_A_B is ambiguous, it names a.b and a_b",
+		},
+		{
+			name:    "ambiguity resolved with __",
+			environ: []string{"SIGNER_A__B=3"},
+			want:    map[string]interface{}{"a.b": "3", "a_b": 1},
+		},
+		{
+			name:    "unknown key of a section",
+			environ: []string{"SIGNER_LOG_FORMAT=json", "SIGNER_KEY_PASS=secret", "SIGNER_CONFIG=/etc/signer.yaml"},
+			want:    map[string]interface{}{"log.format": nil, "key.pass": nil, "config": nil},
+			ignored: []string{"SIGNER_LOG_FORMAT"},
+		},
+		{
+			name:    "invalid yaml",
+			environ: []string{"SIGNER_ADMIN_TOKENS={ops"},
+			wantErr: "invalid SIGNER_ADMIN_TOKENS",
+		},
+		{
+			name:    "other variables",
+			environ: []string{"HOME=/root", "LOG_LEVEL=debug"},
+			want:    map[string]interface{}{"log.level": "info"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vr := viper.New()
+			vr.SetConfigType("yaml")
+			if err := vr.ReadConfig(strings.NewReader(testConfig)); err != nil {
+				t.Fatal(err)
+			}
+
+			ignored, err := applyEnv(vr, tt.environ)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ignored, tt.ignored) {
+				t.Errorf("ignored = %v, want %v", ignored, tt.ignored)
+			}
+			for key, want := range tt.want {
+				if got := vr.Get(key); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %#v, want %#v", key, got, want)
+				}
+			}
+		})
+	}
+}
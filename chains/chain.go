@@ -1,11 +1,13 @@
 package chains
 
 import (
+	"crypto/ecdsa"
 	"evm-signer/chains/ethereum"
 	_interface "evm-signer/chains/interface"
 )
 
-func GetChain(chainId uint64, chainTy, priKey string) (_interface.IChain, error) {
+// GetChain the signer of a chain type with the private key, the caller owns the key and zeroes it once signed
+func GetChain(chainId uint64, chainTy string, priKey *ecdsa.PrivateKey) (_interface.IChain, error) {
 	switch ChainTy(chainTy) {
 	case EthereumTy:
 		return ethereum.NewEthChain(chainId, priKey), nil
@@ -1,6 +1,7 @@
 package ethereum
 
 import (
+	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -11,10 +12,10 @@ import (
 
 type EthChain struct {
 	chainId uint64
-	priKey  string
+	priKey  *ecdsa.PrivateKey
 }
 
-func NewEthChain(chainId uint64, pri string) *EthChain {
+func NewEthChain(chainId uint64, pri *ecdsa.PrivateKey) *EthChain {
 	return &EthChain{
 		chainId: chainId,
 		priKey:  pri,
@@ -37,11 +38,7 @@ func (ec *EthChain) Sign(txMsg string) (string, error) {
 	}
 
 	signer := ethTypes.LatestSignerForChainID(big.NewInt(int64(ec.chainId)))
-	pri, err := crypto.HexToECDSA(ec.priKey)
-	if err != nil {
-		return "", fmt.Errorf("invalid private key error: %s", err)
-	}
-	signature, err := crypto.Sign(signer.Hash(tx).Bytes(), pri)
+	signature, err := crypto.Sign(signer.Hash(tx).Bytes(), ec.priKey)
 	if err != nil {
 		return "", fmt.Errorf("signature error: %s", err)
 	}
@@ -49,11 +46,7 @@ func (ec *EthChain) Sign(txMsg string) (string, error) {
 }
 
 func (ec *EthChain) Sign712(hash []byte) (string, error) {
-	pri, err := crypto.HexToECDSA(ec.priKey)
-	if err != nil {
-		return "", err
-	}
-	signature, err := crypto.Sign(hash, pri)
+	signature, err := crypto.Sign(hash, ec.priKey)
 	if err == nil {
 		signature[64] += 27
 	}
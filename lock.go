@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lockSource     string
+	lockAccount    string
+	unlockPassFile string
+	unlockServer   bool
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{unlockCmd, lockCmd, keysCmd} {
+		cmd.PersistentFlags().StringVar(&adminURL, "url", "http://127.0.0.1:8080", "url of the running signer")
+		cmd.PersistentFlags().StringVar(&adminToken, "token", "", "admin token, default $SIGNER_ADMIN_TOKEN")
+	}
+	for _, cmd := range []*cobra.Command{unlockCmd, lockCmd} {
+		cmd.Flags().StringVar(&lockSource, "source", "", "the key in the account config, eg. account, account.keys.2 or treasury")
+		cmd.Flags().StringVar(&lockAccount, "account", "", "an account of the key, its address, source:index or alias")
+	}
+	unlockCmd.Flags().StringVar(&unlockPassFile, "pass-file", "", "read the passphrase from a file instead of the prompt")
+	unlockCmd.Flags().BoolVar(&unlockServer, "use-source", false, "let the signer read the passphrase source of its config")
+	unlockCmd.AddCommand(keysCmd)
+}
+
+var unlockCmd = &cobra.Command{
+	Use:     "unlock",
+	Short:   "unlock a key of a running signer, the passphrase is prompted for",
+	Example: "./signer unlock --source account.keys.2\n./signer unlock --account 0xDD1e... --use-source",
+	Run: func(cmd *cobra.Command, args []string) {
+		if lockSource == "" && lockAccount == "" {
+			fmt.Println("--source or --account is required, `signer unlock keys` lists the keys")
+			os.Exit(1)
+		}
+
+		if !unlockServer && !strings.HasPrefix(adminURL, "https://") {
+			fmt.Println("a passphrase is only sent over https, use an https --url or --use-source")
+			os.Exit(1)
+		}
+
+		pass := ""
+		switch {
+		case unlockServer:
+		case unlockPassFile != "":
+			data, err := os.ReadFile(unlockPassFile)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			pass = strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+		default:
+			var err error
+			pass, err = prompt.Stdin.PromptPassword("Password: ")
+			if err != nil {
+				fmt.Printf("Failed to read password: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if pass == "" && !unlockServer {
+			fmt.Println("passphrase is empty, use --use-source to read the passphrase source of the signer config")
+			os.Exit(1)
+		}
+
+		if err := lockRequest("/admin/v1/unlock", pass); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var lockCmd = &cobra.Command{
+	Use:     "lock",
+	Short:   "lock a key of a running signer, every key without --source or --account",
+	Example: "./signer lock --source account",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := lockRequest("/admin/v1/lock", ""); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var keysCmd = &cobra.Command{
+	Use:     "keys",
+	Short:   "list the keys of a running signer and whether they are locked",
+	Example: "./signer unlock keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := adminRequest(http.MethodGet, "/admin/v1/keys", nil); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func lockRequest(path, pass string) error {
+	body, err := json.Marshal(map[string]string{
+		"source":     lockSource,
+		"account":    lockAccount,
+		"passphrase": pass,
+	})
+	if err != nil {
+		return err
+	}
+	return adminRequest(http.MethodPost, path, bytes.NewReader(body))
+}
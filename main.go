@@ -3,8 +3,13 @@ package main
 import (
 	"context"
 	"evm-signer/base"
-	"evm-signer/service"
 	"evm-signer/pkg/logging"
+	"evm-signer/service"
+	"evm-signer/service/metrics"
+	"evm-signer/service/policy"
+	ruleLib "evm-signer/service/rules"
+	"evm-signer/service/webhook"
+	"fmt"
 	"github.com/spf13/cobra"
 	"log"
 	"net/http"
@@ -17,9 +22,10 @@ import (
 )
 
 var (
-	port     int
-	ruleFile string
-	logger   *logging.SugaredLogger
+	port       int
+	ruleFile   string
+	configFile string
+	logger     *logging.SugaredLogger
 )
 
 func init() {
@@ -34,32 +40,70 @@ You can decide which transactions are allowed to be signed through the rule.json
 }
 
 func main() {
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "config file, default $SIGNER_CONFIG or config.yaml in ./conf, ../conf or ../../conf")
+	cobra.OnInitialize(func() { base.SetConfigFile(configFile) })
 	startCmd.PersistentFlags().IntVarP(&port, "port", "p", 80, "specify the port on which the signer run")
-	startCmd.PersistentFlags().StringVarP(&ruleFile, "rule", "r", "rule.json", "rule file name, eg. rule.json")
+	startCmd.PersistentFlags().StringVarP(&ruleFile, "rule", "r", "rule.json", "rule file or directory, a bare name is looked up in conf, eg. rule.json")
 	rootCmd.AddCommand(keyCmd)
+	rootCmd.AddCommand(accountCmd)
+	rootCmd.AddCommand(mnemonicCmd)
 	rootCmd.AddCommand(startCmd)
-	_ = rootCmd.Execute()
+	rootCmd.AddCommand(ruleCmd)
+	rootCmd.AddCommand(approvalCmd)
+	rootCmd.AddCommand(addressesCmd)
+	rootCmd.AddCommand(unlockCmd)
+	rootCmd.AddCommand(lockCmd)
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
 
 var startCmd = &cobra.Command{
 	Use:     "start",
 	Short:   "signer start",
 	Example: "./signer start --port 8080",
-	Run: func(cmd *cobra.Command, args []string) {
-		service.SetLogger(base.GetLogger("signer").Sugar())
-		signerConfig := base.GetSignerConfig(ruleFile)
-		accountForAddr, accountForIndex, iAccount := service.GetAccount(signerConfig)
+	// the failures are logged already, cobra only hands them on for the exit status
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		signerConfig, err := loadSignerConfig()
+		if err != nil {
+			logger.Errorf("load config fail: %s", err.Error())
+			return err
+		}
+		keyring, iAccount, err := service.GetKeyring(signerConfig)
+		if err != nil {
+			logger.Errorf("load accounts fail: %s", err.Error())
+			return err
+		}
 		chains, err := service.GetChain(signerConfig)
 		if err != nil {
 			logger.Errorf("get chain fail: %s", err.Error())
-			return
+			return err
 		}
 
 		// init rule
+		if err = ruleLib.SetScriptConfig(service.GetScriptConfig(signerConfig)); err != nil {
+			logger.Errorf("script config fail: %s", err.Error())
+			return err
+		}
+		problems := service.LintRules(signerConfig, chains)
+		for _, problem := range problems {
+			if problem.Level == ruleLib.LevelError {
+				logger.Errorf("%s", problem)
+			} else {
+				logger.Warnf("%s", problem)
+			}
+		}
+		if ruleLib.HasErrors(problems) {
+			err = fmt.Errorf("invalid rules in %s, run `signer rules validate` for details", signerConfig.RulePath)
+			logger.Errorf("%s", err)
+			return err
+		}
 		rules, err := service.GetRuleConfig(signerConfig)
 		if err != nil {
 			logger.Errorf("get rule fail: %s", err.Error())
-			return
+			return err
 		}
 
 		authConfig := service.GetAuthConfig(signerConfig)
@@ -68,14 +112,65 @@ var startCmd = &cobra.Command{
 		svc, err := service.New(iAccount, whitelist)
 		if err != nil {
 			logger.Errorf("service initialization fail: %s", err.Error())
-			return
+			return err
 		}
 
-		svc.SetAccountMap(accountForAddr)
-		svc.SetAccountListMap(accountForIndex)
+		if err = svc.SetKeyring(keyring, service.GetLockConfig(signerConfig)); err != nil {
+			logger.Errorf("unlock accounts fail: %s", err.Error())
+			return err
+		}
 		svc.SetChainMap(chains)
 		svc.SetRules(rules)
 
+		limiter, err := service.NewRateLimiter(service.GetRateLimitConfig(signerConfig))
+		if err != nil {
+			logger.Errorf("rate limiter initialization fail: %s", err.Error())
+			return err
+		}
+		svc.SetRateLimiter(limiter)
+
+		notifier, err := webhook.New(service.GetWebhookConfig(signerConfig))
+		if err != nil {
+			logger.Errorf("webhook initialization fail: %s", err.Error())
+			return err
+		}
+		svc.SetNotifier(notifier)
+
+		policyClient, err := policy.New(service.GetPolicyConfig(signerConfig))
+		if err != nil {
+			logger.Errorf("policy initialization fail: %s", err.Error())
+			return err
+		}
+		if policyClient != nil {
+			logger.Infof("signing decisions are checked by the policy at [ %s ]", policyClient.URL())
+		}
+		svc.SetPolicy(policyClient)
+
+		svc.SetAdminConfig(service.GetAdminConfig(signerConfig))
+		approvals, err := service.NewApprovalQueue(service.GetApprovalConfig(signerConfig))
+		if err != nil {
+			logger.Errorf("approval queue initialization fail: %s", err.Error())
+			return err
+		}
+		svc.SetApprovalQueue(approvals)
+
+		allocator, err := service.NewAllocator(service.GetAllocationConfig(signerConfig))
+		if err != nil {
+			logger.Errorf("allocator initialization fail: %s", err.Error())
+			return err
+		}
+		svc.SetAllocator(allocator)
+
+		nonceConfig := service.GetNonceConfig(signerConfig)
+		if nonceConfig.Enable {
+			nonces, err := service.NewNonceTracker(nonceConfig)
+			if err != nil {
+				logger.Errorf("nonce tracker initialization fail: %s", err.Error())
+				return err
+			}
+			svc.SetNonceTracker(nonces)
+		}
+
 		httpConfig := service.GetHttpConfig(signerConfig)
 		_port := 0
 		if port != 80 {
@@ -90,13 +185,38 @@ var startCmd = &cobra.Command{
 			MaxHeaderBytes: 1 << 20,
 		}
 
+		notifyCtx, stopNotify := context.WithCancel(context.Background())
+		defer stopNotify()
+		go notifier.Run(notifyCtx)
+
 		go func() {
-			if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			var err error
+			if httpConfig.SSLEnable {
+				err = s.ListenAndServeTLS(httpConfig.SSLCertPath, httpConfig.SSLCertKeyPath)
+			} else {
+				err = s.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
 				log.Fatalf("s.ListenAndServe err: %v", err)
 			}
 		}()
 
-		quit := make(chan os.Signal)
+		// metrics on their own listener, so scrapers never reach the signing api
+		var metricsServer *http.Server
+		if metricsConfig := service.GetMetricsConfig(signerConfig); metricsConfig.Listen != "" {
+			metricsServer = &http.Server{
+				Addr:    metricsConfig.Listen,
+				Handler: metrics.Handler(),
+			}
+			go func() {
+				if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Fatalf("metrics ListenAndServe err: %v", err)
+				}
+			}()
+			logger.Infof("metrics listen on [ %s ]", metricsConfig.Listen)
+		}
+
+		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		<-quit
 		log.Println("Shuting down server...")
@@ -106,7 +226,22 @@ var startCmd = &cobra.Command{
 		if err := s.Shutdown(ctx); err != nil {
 			log.Fatal("Server forced to shutdown:", err)
 		}
+		if metricsServer != nil {
+			metricsServer.Shutdown(ctx)
+		}
 
 		log.Println("Server exiting")
+		return nil
 	},
 }
+
+// loadSignerConfig loads the config and the rules, the loggers follow the log config once it is loaded
+func loadSignerConfig() (*base.SignerConfig, error) {
+	signerConfig, err := base.GetSignerConfig(ruleFile)
+	if err != nil {
+		return nil, err
+	}
+	logger = base.GetLogger("signer").Sugar()
+	service.SetLogger(logger)
+	return signerConfig, nil
+}
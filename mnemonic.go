@@ -0,0 +1,115 @@
+package main
+
+import (
+	"evm-signer/base"
+	"evm-signer/pkg/ethutils"
+	"evm-signer/service"
+	"evm-signer/service/account"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mnemonicOut      string
+	mnemonicFile     string
+	mnemonicScryptN  int
+	mnemonicScryptP  int
+	mnemonicIndex    string
+	mnemonicPath     string
+	mnemonicSeedFile string
+)
+
+func init() {
+	mnemonicEncryptCmd.Flags().StringVar(&mnemonicOut, "out", "", "the encrypted mnemonic file to create")
+	mnemonicEncryptCmd.Flags().StringVar(&mnemonicFile, "mnemonic-file", "", "read the mnemonic from a file, - for stdin, instead of the prompt")
+	mnemonicEncryptCmd.Flags().StringVar(&passFile, "pass-file", "", "read the passphrase from a file instead of the prompt")
+	mnemonicEncryptCmd.Flags().IntVar(&mnemonicScryptN, "scrypt-n", keystore.StandardScryptN, "scrypt cost, a power of 2")
+	mnemonicEncryptCmd.Flags().IntVar(&mnemonicScryptP, "scrypt-p", keystore.StandardScryptP, "scrypt parallelization")
+	_ = mnemonicEncryptCmd.MarkFlagRequired("out")
+
+	mnemonicVerifyCmd.Flags().StringVar(&passFile, "pass-file", "", "read the passphrase from a file instead of the prompt")
+	mnemonicVerifyCmd.Flags().StringVar(&mnemonicIndex, "index", "0-4", "the account indices to derive, eg. 0-9,256")
+	mnemonicVerifyCmd.Flags().StringVar(&mnemonicPath, "path", ethutils.DefaultPath, "the derivation path, {index} is replaced by each index")
+	mnemonicVerifyCmd.Flags().StringVar(&mnemonicSeedFile, "seed-pass-file", "", "read the BIP-39 passphrase of the mnemonic from a file")
+
+	mnemonicCmd.AddCommand(mnemonicEncryptCmd)
+	mnemonicCmd.AddCommand(mnemonicVerifyCmd)
+}
+
+var mnemonicCmd = &cobra.Command{
+	Use:   "mnemonic",
+	Short: "create and check the encrypted mnemonic files of EncryptedMnemonic accounts.",
+}
+
+var mnemonicEncryptCmd = &cobra.Command{
+	Use:     "encrypt",
+	Short:   "encrypt a BIP-39 mnemonic into a file for an EncryptedMnemonic account",
+	Example: "./signer mnemonic encrypt --out conf/mnemonic.json\ncat words.txt | ./signer mnemonic encrypt --out conf/mnemonic.json --mnemonic-file - --pass-file pass.txt",
+	Run: func(cmd *cobra.Command, args []string) {
+		mnemonic := strings.Join(strings.Fields(readSecret(mnemonicFile, "Mnemonic: ")), " ")
+		if err := ethutils.ValidateMnemonic(mnemonic); err != nil {
+			exitf("%s", err)
+		}
+		if mnemonicScryptN <= 1 || mnemonicScryptN&(mnemonicScryptN-1) != 0 || mnemonicScryptP <= 0 {
+			exitf("--scrypt-n must be a power of 2 and --scrypt-p positive")
+		}
+		if _, err := os.Stat(mnemonicOut); err == nil {
+			exitf("%s exists, it is never overwritten", mnemonicOut)
+		}
+
+		pass := readPassphrase(passFile, "please enter a password for the encrypted mnemonic", true)
+		if err := account.WriteEncryptedMnemonic(mnemonicOut, mnemonic, pass, mnemonicScryptN, mnemonicScryptP); err != nil {
+			exitf("encrypt mnemonic: %s", err)
+		}
+		fmt.Printf("encrypted mnemonic: %s\nverify it with: ./signer mnemonic verify %s\n", mnemonicOut, mnemonicOut)
+	},
+}
+
+var mnemonicVerifyCmd = &cobra.Command{
+	Use:     "verify <file>",
+	Short:   "decrypt an encrypted mnemonic file and print the addresses of an index range",
+	Example: "./signer mnemonic verify conf/mnemonic.json --index 0-9",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger = base.GetLogger("signer").Sugar()
+		service.SetLogger(logger)
+
+		if err := ethutils.ValidatePath(mnemonicPath); err != nil {
+			exitf("%s", err)
+		}
+		seedPass := ""
+		if mnemonicSeedFile != "" {
+			data, err := os.ReadFile(mnemonicSeedFile)
+			if err != nil {
+				exitf("%s", err)
+			}
+			seedPass = strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+		}
+
+		pass := readPassphrase(passFile, "please enter the password of the encrypted mnemonic", false)
+		em, err := account.NewEncryptedMnemonic(args[0], pass, mnemonicIndex, mnemonicPath, seedPass)
+		if err != nil {
+			exitf("%s", err)
+		}
+		accounts, err := em.Decrypt()
+		if err != nil {
+			exitf("%s", err)
+		}
+
+		sort.Slice(accounts, func(i, j int) bool {
+			return accounts[i].Index < accounts[j].Index
+		})
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "INDEX\tADDRESS")
+		for _, _account := range accounts {
+			fmt.Fprintf(w, "%d\t%s\n", _account.Index, _account.Address.Hex())
+		}
+		_ = w.Flush()
+	},
+}
@@ -2,6 +2,9 @@ package ethutils
 
 import (
 	"crypto/ecdsa"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcutil/hdkeychain"
@@ -17,46 +20,208 @@ type Account struct {
 	PrivateKey *ecdsa.PrivateKey
 }
 
+// DefaultPath the standard Ethereum derivation path, {index} is replaced by the account index
+const (
+	IndexPlaceholder = "{index}"
+	DefaultPath      = "m/44'/60'/0'/0/" + IndexPlaceholder
+)
+
 // GetAccountFromMnemonic derives an account from a mnemonic at the given index
 func GetAccountFromMnemonic(mnemonic string, index int) *Account {
-	seed := bip39.NewSeed(mnemonic, "")
-
-	// Derive the master key
-	masterKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	account, err := DeriveAccount(mnemonic, "", DefaultPath, index)
 	if err != nil {
 		return nil
 	}
+	return account
+}
+
+// ValidateMnemonic checks the words and the checksum of a BIP-39 mnemonic
+func ValidateMnemonic(mnemonic string) error {
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return fmt.Errorf("invalid mnemonic, unknown word or wrong checksum")
+	}
+	return nil
+}
+
+// ValidatePath checks a derivation path, it must contain the {index} placeholder once,
+// eg. m/44'/60'/0'/0/{index} or m/44'/60'/{index}'/0/0
+func ValidatePath(path string) error {
+	if strings.Count(path, IndexPlaceholder) != 1 {
+		return fmt.Errorf("derivation path %s must contain %s once", path, IndexPlaceholder)
+	}
+	_, err := DerivationPath(path, 0)
+	return err
+}
+
+// HardenedIndex whether the {index} element of a path is hardened, eg. m/44'/60'/{index}'/0/0, no extended
+// public key derives the addresses of such a path
+func HardenedIndex(path string) bool {
+	for _, element := range strings.Split(path, "/") {
+		if strings.Contains(element, IndexPlaceholder) {
+			return strings.HasSuffix(strings.TrimSpace(element), "'")
+		}
+	}
+	return false
+}
+
+// XPubPath the path of the key above the {index} element, whose xpub derives the addresses of the path,
+// eg. m/44'/60'/0'/0 for the default path, the external chain of the BIP-44 account m/44'/60'/0'.
+// Empty for a hardened {index}
+func XPubPath(path string) string {
+	if HardenedIndex(path) {
+		return ""
+	}
+	full := strings.TrimSpace(path)
+	if !strings.HasPrefix(full, "m/") {
+		full = accounts.DefaultRootDerivationPath.String() + "/" + full
+	}
+	position := strings.Index(full, IndexPlaceholder)
+	if position < 0 {
+		return ""
+	}
+	return strings.TrimSuffix(full[:position], "/")
+}
+
+// DerivationPath the path of the account index
+func DerivationPath(path string, index int) (accounts.DerivationPath, error) {
+	if index < 0 || uint32(index) >= hdkeychain.HardenedKeyStart {
+		return nil, fmt.Errorf("account index %d out of range", index)
+	}
+	derivationPath, err := accounts.ParseDerivationPath(strings.ReplaceAll(path, IndexPlaceholder, strconv.Itoa(index)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid derivation path %s: %s", path, err)
+	}
+	return derivationPath, nil
+}
+
+// DeriveAccount derives the account of the index from a mnemonic and its BIP-39 passphrase
+func DeriveAccount(mnemonic, passphrase, path string, index int) (*Account, error) {
+	if _, err := DerivationPath(path, index); err != nil {
+		return nil, err
+	}
+	deriver, err := NewDeriver(mnemonic, passphrase, path)
+	if err != nil {
+		return nil, err
+	}
+	return deriver.Derive(index)
+}
+
+// Deriver derives the accounts of a path from its parent key, the key above the {index} element, eg.
+// m/44'/60'/0'/0 for the default path, which is derived from the seed once. Derive is safe for concurrent use
+type Deriver struct {
+	path     string
+	parent   *hdkeychain.ExtendedKey // the key above the {index} element
+	xpub     string                  // its extended public key
+	hardened bool                    // the {index} element is hardened, eg. m/44'/60'/{index}'/0/0
+	suffix   accounts.DerivationPath // the elements below the {index} element
+}
+
+// NewDeriver derives the parent key of the path from a mnemonic and its BIP-39 passphrase
+func NewDeriver(mnemonic, passphrase, path string) (*Deriver, error) {
+	if err := ValidatePath(path); err != nil {
+		return nil, err
+	}
+	full := strings.TrimSpace(path)
+	if !strings.HasPrefix(full, "m/") {
+		// a relative path is below the default root, as accounts.ParseDerivationPath reads it
+		full = accounts.DefaultRootDerivationPath.String() + "/" + full
+	}
+	elements := strings.Split(strings.TrimPrefix(full, "m/"), "/")
+	position := 0
+	for position < len(elements) && !strings.Contains(elements[position], IndexPlaceholder) {
+		position++
+	}
+	element := strings.TrimSpace(elements[position])
+	if element != IndexPlaceholder && element != IndexPlaceholder+"'" {
+		return nil, fmt.Errorf("derivation path %s: %s must be a whole element, eg. %s or %s'",
+			path, IndexPlaceholder, IndexPlaceholder, IndexPlaceholder)
+	}
 
-	// Standard Ethereum derivation path: m/44'/60'/0'/0/index
-	path := accounts.DefaultBaseDerivationPath
-	path[len(path)-1] = uint32(index)
+	d := &Deriver{path: path, hardened: element != IndexPlaceholder}
+	if position+1 < len(elements) {
+		suffix, err := accounts.ParseDerivationPath("m/" + strings.Join(elements[position+1:], "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path %s: %s", path, err)
+		}
+		d.suffix = suffix
+	}
 
-	key := masterKey
-	for _, n := range path {
-		key, err = key.Child(n)
+	key, err := hdkeychain.NewMaster(bip39.NewSeed(mnemonic, passphrase), &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+	if position != 0 {
+		prefix, err := accounts.ParseDerivationPath("m/" + strings.Join(elements[:position], "/"))
 		if err != nil {
-			return nil
+			return nil, fmt.Errorf("invalid derivation path %s: %s", path, err)
+		}
+		for _, n := range prefix {
+			if key, err = key.Child(n); err != nil {
+				return nil, err
+			}
 		}
 	}
+	// neutering memoizes the public key of the parent, its children are derived concurrently later
+	xpub, err := key.Neuter()
+	if err != nil {
+		return nil, err
+	}
+	d.parent, d.xpub = key, xpub.String()
+	return d, nil
+}
 
-	privateKey, err := key.ECPrivKey()
+// Path the derivation path with the {index} placeholder
+func (d *Deriver) Path() string {
+	return d.path
+}
+
+// PathOf the derivation path of the index, eg. m/44'/60'/0'/0/5
+func (d *Deriver) PathOf(index int) string {
+	path, err := DerivationPath(d.path, index)
 	if err != nil {
-		return nil
+		return ""
 	}
+	return path.String()
+}
 
-	privateKeyECDSA := privateKey.ToECDSA()
-	publicKey := privateKeyECDSA.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil
+// XPub the extended public key of the parent key, the key at XPubPath of the path, it derives the addresses without any private
+// key. Empty for a hardened {index}, whose addresses no public key derives
+func (d *Deriver) XPub() string {
+	if d.hardened {
+		return ""
 	}
+	return d.xpub
+}
 
-	address := crypto.PubkeyToAddress(*publicKeyECDSA)
+// Derive the account of the index
+func (d *Deriver) Derive(index int) (*Account, error) {
+	if index < 0 || uint32(index) >= hdkeychain.HardenedKeyStart {
+		return nil, fmt.Errorf("account index %d out of range", index)
+	}
+	n := uint32(index)
+	if d.hardened {
+		n += hdkeychain.HardenedKeyStart
+	}
+	key, err := d.parent.Child(n)
+	if err != nil {
+		return nil, err
+	}
+	for _, n = range d.suffix {
+		if key, err = key.Child(n); err != nil {
+			return nil, err
+		}
+	}
 
+	privateKey, err := key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeyECDSA := privateKey.ToECDSA()
 	return &Account{
-		Address:    address,
+		Address:    crypto.PubkeyToAddress(privateKeyECDSA.PublicKey),
 		PrivateKey: privateKeyECDSA,
-	}
+	}, nil
 }
 
 // GetAccountFromPStr creates an account from a private key string
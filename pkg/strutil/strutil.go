@@ -58,3 +58,80 @@ func SplitNum(rangeStr string) (map[string]struct{}, error) {
 
 	return result, nil
 }
+
+// Range an inclusive range of numbers
+type Range struct {
+	Start int64
+	End   int64
+}
+
+// Ranges the ranges of a range string, kept as ranges so a huge range costs no memory
+type Ranges []Range
+
+// ParseRanges parses a range string like "0-9,11,15-20" the same way SplitNum does
+func ParseRanges(rangeStr string) (Ranges, error) {
+	var ranges Ranges
+	for _, part := range strings.Split(rangeStr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := []string{part, part}
+		if strings.Contains(part, "-") {
+			if bounds = strings.Split(part, "-"); len(bounds) != 2 {
+				return nil, fmt.Errorf("invalid range format: %s", part)
+			}
+		}
+		start, err := strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start number in range %s: %v", part, err)
+		}
+		end, err := strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end number in range %s: %v", part, err)
+		}
+		if start > end {
+			return nil, fmt.Errorf("invalid range %s: start > end", part)
+		}
+		ranges = append(ranges, Range{Start: start, End: end})
+	}
+	return ranges, nil
+}
+
+// Contains whether n is in one of the ranges
+func (r Ranges) Contains(n int64) bool {
+	for _, _range := range r {
+		if n >= _range.Start && n <= _range.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Next the smallest number of the ranges >= n, false when there is none
+func (r Ranges) Next(n int64) (int64, bool) {
+	next, ok := int64(0), false
+	for _, _range := range r {
+		if _range.End < n {
+			continue
+		}
+		candidate := _range.Start
+		if candidate < n {
+			candidate = n
+		}
+		if !ok || candidate < next {
+			next, ok = candidate, true
+		}
+	}
+	return next, ok
+}
+
+// Len how many numbers the ranges hold, overlaps counted twice
+func (r Ranges) Len() int64 {
+	var n int64
+	for _, _range := range r {
+		n += _range.End - _range.Start + 1
+	}
+	return n
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"evm-signer/base"
+	"evm-signer/pkg/logging"
+	"evm-signer/service"
+	"evm-signer/service/rules"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	fixtureFile string
+	verbose     bool
+	benchRounds int
+)
+
+func init() {
+	ruleCmd.PersistentFlags().StringVarP(&ruleFile, "rule", "r", "rule.json", "rule file or directory, a bare name is looked up in conf, eg. rule.json")
+	testCmd.Flags().StringVarP(&fixtureFile, "fixtures", "f", "", "JSONL file of requests with their expected outcome")
+	testCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "log the rule evaluation")
+	_ = testCmd.MarkFlagRequired("fixtures")
+	benchCmd.Flags().StringVarP(&fixtureFile, "fixtures", "f", "", "JSONL file of requests to match")
+	benchCmd.Flags().IntVarP(&benchRounds, "rounds", "n", 1000, "times every fixture is matched")
+	_ = benchCmd.MarkFlagRequired("fixtures")
+	ruleCmd.AddCommand(validateCmd)
+	ruleCmd.AddCommand(testCmd)
+	ruleCmd.AddCommand(benchCmd)
+}
+
+var ruleCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "check rule files without starting the signer.",
+}
+
+var validateCmd = &cobra.Command{
+	Use:     "validate",
+	Short:   "validate the rule file",
+	Example: "./signer rules validate --rule rule.json",
+	Run: func(cmd *cobra.Command, args []string) {
+		signerConfig, err := loadSignerConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR %s\n", err)
+			os.Exit(1)
+		}
+		chains, err := service.GetChain(signerConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR get chain config: %s\n", err)
+			os.Exit(1)
+		}
+
+		if err = rules.SetScriptConfig(service.GetScriptConfig(signerConfig)); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR script config: %s\n", err)
+			os.Exit(1)
+		}
+
+		problems := service.LintRules(signerConfig, chains)
+		errCount := 0
+		for _, problem := range problems {
+			fmt.Println(problem.String())
+			if problem.Level == rules.LevelError {
+				errCount++
+			}
+		}
+
+		if errCount > 0 {
+			fmt.Printf("%s: %d errors\n", signerConfig.RulePath, errCount)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: ok\n", signerConfig.RulePath)
+	},
+}
+
+var testCmd = &cobra.Command{
+	Use:     "test",
+	Short:   "run fixture requests through the rules and check the outcomes, no keys are loaded",
+	Example: "./signer rules test --rule rule.json --fixtures rule_test.jsonl",
+	Run: func(cmd *cobra.Command, args []string) {
+		rs, chains, fixtures := loadFixtures()
+		matcher := rules.Compile(rs)
+
+		failed := 0
+		for _, fixture := range fixtures {
+			outcome, ruleName, reason := rules.ExpectDeny, "", "no rule matched"
+			matchRule, tx, err := fixture.Match(matcher)
+			switch {
+			case err != nil:
+				reason = err.Error()
+			case matchRule != nil:
+				outcome, ruleName, reason = rules.ExpectAllow, matchRule.Name, "rule "+matchRule.Name
+				if chain, ok := chains[uint64(fixture.ChainId)]; ok && tx != nil {
+					if err = chain.CheckCeilings(matchRule, tx); err != nil {
+						outcome, reason = rules.ExpectDeny, err.Error()
+					}
+				}
+			}
+
+			if outcome == fixture.Expect && (fixture.Rule == "" || fixture.Rule == ruleName) {
+				fmt.Printf("PASS %s:%d %s: %s\n", fixtureFile, fixture.Line, fixture.Name, reason)
+				continue
+			}
+			failed++
+			expected := fixture.Expect
+			if fixture.Rule != "" {
+				expected += " by rule " + fixture.Rule
+			}
+			fmt.Printf("FAIL %s:%d %s: expected %s, got %s (%s)\n",
+				fixtureFile, fixture.Line, fixture.Name, expected, outcome, reason)
+		}
+
+		fmt.Printf("%d fixtures, %d passed, %d failed\n", len(fixtures), len(fixtures)-failed, failed)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var benchCmd = &cobra.Command{
+	Use:     "bench",
+	Short:   "time the compiled rule matcher against the linear rule scan on fixture requests",
+	Example: "./signer rules bench --rule rule.json --fixtures rule_test.jsonl --rounds 1000",
+	Run: func(cmd *cobra.Command, args []string) {
+		rs, _, fixtures := loadFixtures()
+		matcher := rules.Compile(rs)
+
+		var requests []func(rs rules.RuleMatcher) *rules.Rule
+		for _, fixture := range fixtures {
+			match, _, err := fixture.Prepare()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR %s:%d %s: %s\n", fixtureFile, fixture.Line, fixture.Name, err)
+				os.Exit(1)
+			}
+			// both must allow by the same rule
+			if linear, compiled := match(rs), match(matcher); linear != compiled {
+				fmt.Fprintf(os.Stderr, "ERROR %s:%d %s: linear match %s, compiled match %s\n",
+					fixtureFile, fixture.Line, fixture.Name, ruleName(linear), ruleName(compiled))
+				os.Exit(1)
+			}
+			requests = append(requests, match)
+		}
+		if len(requests) == 0 || benchRounds <= 0 {
+			fmt.Println("nothing to match")
+			return
+		}
+
+		run := func(name string, rm rules.RuleMatcher) {
+			start := time.Now()
+			for i := 0; i < benchRounds; i++ {
+				for _, match := range requests {
+					match(rm)
+				}
+			}
+			elapsed := time.Since(start)
+			fmt.Printf("%-8s %d matches in %s, %s/match\n", name, benchRounds*len(requests), elapsed,
+				elapsed/time.Duration(benchRounds*len(requests)))
+		}
+		fmt.Printf("%d rules, %d fixtures\n", rs.Length(), len(requests))
+		run("linear", rs)
+		run("compiled", matcher)
+	},
+}
+
+// loadFixtures loads the checked rules, the chains and the fixtures, rule logs are muted unless verbose
+func loadFixtures() (rules.Rules, map[uint64]*service.ChainConfig, []*rules.Fixture) {
+	signerConfig, err := loadSignerConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR %s\n", err)
+		os.Exit(1)
+	}
+	if !verbose {
+		rules.SetLogger(logging.GetLogger(base.ServiceName, "rules", &logging.LogConfig{Level: "error"}).Sugar())
+	}
+	chains, err := service.GetChain(signerConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR get chain config: %s\n", err)
+		os.Exit(1)
+	}
+
+	if err = rules.SetScriptConfig(service.GetScriptConfig(signerConfig)); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR script config: %s\n", err)
+		os.Exit(1)
+	}
+
+	problems := service.LintRules(signerConfig, chains)
+	if rules.HasErrors(problems) {
+		for _, problem := range problems {
+			fmt.Println(problem.String())
+		}
+		os.Exit(1)
+	}
+	rs, err := service.GetRuleConfig(signerConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR parse rules: %s\n", err)
+		os.Exit(1)
+	}
+	rs.Init()
+
+	f, err := os.Open(fixtureFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR open fixtures: %s\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	fixtures, err := rules.ReadFixtures(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR read fixtures %s: %s\n", fixtureFile, err)
+		os.Exit(1)
+	}
+	return rs, chains, fixtures
+}
+
+func ruleName(rule *rules.Rule) string {
+	if rule == nil {
+		return "none"
+	}
+	return rule.Name
+}
@@ -1,39 +1,227 @@
 package service
 
 import (
-	"encoding/json"
 	"evm-signer/base"
 	"evm-signer/service/account"
+	"evm-signer/service/metrics"
+	"evm-signer/service/policy"
 	"evm-signer/service/rules"
+	"evm-signer/service/webhook"
 	"evm-signer/types"
+	"fmt"
+	"strconv"
 	"strings"
 )
 
-// GetAccount
-// accountForNameMap  [name:index]*types.Account
-// accountForIndexMap [index]*types.Account
-func GetAccount(scfg *base.SignerConfig) (map[string]*types.Account, map[int64]*types.Account, account.IAccount) {
-	accountForAddrMap := make(map[string]*types.Account)
-	accountForIndexMap := make(map[int64]*types.Account)
-	var insAccount account.IAccount
+// GetKeyring the keys and the plain accounts of the account sources, the keys are unlocked by Service.SetKeyring.
+// The error names every account source which failed to load
+func GetKeyring(scfg *base.SignerConfig) (*account.Keyring, account.IAccount, error) {
+	sources, err := GetAccountSources(scfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyring, err := account.NewKeyring(sources...)
+	if err != nil {
+		return nil, nil, err
+	}
+	accountTy, _ := sources[0].Params["type"].(string)
+	return keyring, account.NewAccount(accountTy, sources[0].Params), nil
+}
 
-	accountInfo := scfg.Config.GetStringMap("account")
-	if _, ok := accountInfo["type"]; !ok {
-		logger.Fatalf("account config error, account.type field not exists.")
+// GetAccountSources the named account sources of the accounts list, or the single account block
+// as a source named account
+func GetAccountSources(scfg *base.SignerConfig) ([]*account.Source, error) {
+	list := scfg.Config.Get("accounts")
+	if list != nil && scfg.Config.IsSet("account") {
+		return nil, fmt.Errorf("account config error, set either account or accounts, not both")
+	}
+	if list == nil {
+		params := scfg.Config.GetStringMap("account")
+		if _, ok := params["type"].(string); !ok {
+			return nil, fmt.Errorf("account config error, account.type field not exists")
+		}
+		source, err := accountSource(account.RootSource, params)
+		if err != nil {
+			return nil, fmt.Errorf("account config error, account.%s", err)
+		}
+		return []*account.Source{source}, nil
 	}
 
-	insAccount = account.NewAccount(accountInfo["type"].(string), accountInfo)
-	crypto, err := insAccount.Account().Crypto()
-	if err != nil {
-		logger.Fatalf("invalid account config: %s", err)
+	items, ok := list.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("account config error, accounts must be a list of account sources")
+	}
+	sources := make([]*account.Source, 0, len(items))
+	for i, item := range items {
+		params, ok := configMap(item)
+		if !ok {
+			return nil, fmt.Errorf("account config error, accounts[%d] must be a map", i)
+		}
+		name, _ := params["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("account config error, accounts[%d].name field not exists", i)
+		}
+		if _, ok = params["type"].(string); !ok {
+			return nil, fmt.Errorf("account config error, accounts[%d].type field not exists", i)
+		}
+		delete(params, "name")
+		source, err := accountSource(name, params)
+		if err != nil {
+			return nil, fmt.Errorf("account config error, accounts[%d].%s", i, err)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// accountSource takes the aliases and the restrictions out of the config of a source
+func accountSource(name string, params map[string]interface{}) (*account.Source, error) {
+	source := &account.Source{Name: name, Params: params}
+	var err error
+	if source.Aliases, err = accountAliases(params["aliases"]); err != nil {
+		return nil, fmt.Errorf("aliases %s", err)
+	}
+	if source.Restriction, err = accountRestriction(params); err != nil {
+		return nil, err
+	}
+
+	if params["restrict"] != nil {
+		restrict, ok := configMap(params["restrict"])
+		if !ok {
+			return nil, fmt.Errorf("restrict must be a map of account index or alias: restriction")
+		}
+		source.Restrictions = make(map[int64]*account.Restriction, len(restrict))
+		for ref, value := range restrict {
+			index, err := strconv.ParseInt(ref, 10, 64)
+			if err != nil {
+				var ok bool
+				if index, ok = source.Aliases[ref]; !ok {
+					return nil, fmt.Errorf("restrict.%s is neither an account index nor an alias of the source", ref)
+				}
+			}
+			_params, ok := configMap(value)
+			if !ok {
+				return nil, fmt.Errorf("restrict.%s must be a map of chain_ids and operations", ref)
+			}
+			restriction, err := accountRestriction(_params)
+			if err != nil {
+				return nil, fmt.Errorf("restrict.%s.%s", ref, err)
+			}
+			if restriction == nil {
+				restriction = new(account.Restriction)
+			}
+			if _, ok = source.Restrictions[index]; ok {
+				return nil, fmt.Errorf("restrict.%s names the index %d twice", ref, index)
+			}
+			source.Restrictions[index] = restriction
+		}
+	}
+	if params["allocate"] != nil {
+		allocate, ok := params["allocate"].(bool)
+		accountTy, _ := params["type"].(string)
+		if !ok {
+			return nil, fmt.Errorf("allocate must be bool")
+		}
+		if allocate && accountTy != string(account.PlainMnemonicTy) && accountTy != string(account.EncryptedMnemonicTy) {
+			return nil, fmt.Errorf("allocate needs a PlainMnemonic or EncryptedMnemonic source, not %s", accountTy)
+		}
+		source.Allocate = allocate
+	}
+	delete(params, "aliases")
+	delete(params, "restrict")
+	delete(params, "allocate")
+	return source, nil
+}
+
+// accountRestriction the chain_ids and operations of a config map, nil when it has neither
+func accountRestriction(params map[string]interface{}) (*account.Restriction, error) {
+	restriction := new(account.Restriction)
+	if value := params["chain_ids"]; value != nil {
+		chainIds, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("chain_ids must be a list of chain ids")
+		}
+		for _, _chainId := range chainIds {
+			chainId, err := strconv.ParseInt(fmt.Sprint(_chainId), 10, 64)
+			if err != nil || chainId <= 0 {
+				return nil, fmt.Errorf("chain_ids: [ %v ] is not a chain id", _chainId)
+			}
+			restriction.ChainIds = append(restriction.ChainIds, chainId)
+		}
+	}
+	if value := params["operations"]; value != nil {
+		operations, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("operations must be a list of %s", strings.Join(Operations, ", "))
+		}
+		for _, _operation := range operations {
+			operation := fmt.Sprint(_operation)
+			if !isOperation(operation) {
+				return nil, fmt.Errorf("operations: [ %s ] is none of %s", operation, strings.Join(Operations, ", "))
+			}
+			restriction.Operations = append(restriction.Operations, operation)
+		}
+	}
+	delete(params, "chain_ids")
+	delete(params, "operations")
+	if len(restriction.ChainIds) == 0 && len(restriction.Operations) == 0 {
+		return nil, nil
+	}
+	return restriction, nil
+}
+
+// accountAliases the alias: index map of a source
+func accountAliases(value interface{}) (map[string]int64, error) {
+	if value == nil {
+		return nil, nil
+	}
+	params, ok := configMap(value)
+	if !ok {
+		return nil, fmt.Errorf("must be a map of alias: index")
+	}
+	aliases := make(map[string]int64, len(params))
+	for alias, _index := range params {
+		index, err := strconv.ParseInt(fmt.Sprint(_index), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("index of [ %s ] must be a number", alias)
+		}
+		aliases[alias] = index
+	}
+	return aliases, nil
+}
+
+// configMap a map of the config with lower case string keys like viper gives them, the maps of list
+// entries are left as yaml decodes them
+func configMap(value interface{}) (map[string]interface{}, bool) {
+	params := make(map[string]interface{})
+	switch m := value.(type) {
+	case map[string]interface{}:
+		for key, val := range m {
+			params[strings.ToLower(key)] = configValue(val)
+		}
+	case map[interface{}]interface{}:
+		for key, val := range m {
+			params[strings.ToLower(fmt.Sprint(key))] = configValue(val)
+		}
+	default:
+		return nil, false
+	}
+	return params, true
+}
+
+func configValue(value interface{}) interface{} {
+	if params, ok := configMap(value); ok {
+		return params
 	}
+	return value
+}
 
-	accounts := crypto.Decrypt()
-	for _, _account := range accounts {
-		accountForAddrMap[strings.ToLower(_account.Address.Hex())] = _account
-		accountForIndexMap[_account.Index] = _account
+func GetLockConfig(scfg *base.SignerConfig) *LockConfig {
+	lockConfig := new(LockConfig)
+	if err := scfg.Config.UnmarshalKey("lock", lockConfig); err != nil {
+		logger.Fatalf("invalid lock config: %s", err)
 	}
-	return accountForAddrMap, accountForIndexMap, insAccount
+	return lockConfig
 }
 
 func GetChain(scfg *base.SignerConfig) (map[uint64]*ChainConfig, error) {
@@ -53,6 +241,9 @@ func GetChain(scfg *base.SignerConfig) (map[uint64]*ChainConfig, error) {
 		}
 
 		chain.Name = chainName
+		if err := chain.initCeilings(); err != nil {
+			return nil, fmt.Errorf("invalid chain config for %s: %w", chainName, err)
+		}
 		chain.ChainType = strings.ToLower(chain.ChainType)
 		chainMap[chain.ChainId] = chain
 	}
@@ -81,6 +272,62 @@ func GetHttpConfig(scfg *base.SignerConfig) *types.Config {
 	return httpConfig
 }
 
+func GetNonceConfig(scfg *base.SignerConfig) *NonceConfig {
+	nonceConfig := new(NonceConfig)
+	if err := scfg.Config.UnmarshalKey("nonce", nonceConfig); err != nil {
+		logger.Fatalf("invalid nonce config: %s", err)
+	}
+	return nonceConfig
+}
+
+func GetRateLimitConfig(scfg *base.SignerConfig) *RateLimitConfig {
+	rateLimitConfig := new(RateLimitConfig)
+	if err := scfg.Config.UnmarshalKey("rate_limit", rateLimitConfig); err != nil {
+		logger.Fatalf("invalid rate_limit config: %s", err)
+	}
+	return rateLimitConfig
+}
+
+func GetAdminConfig(scfg *base.SignerConfig) *AdminConfig {
+	adminConfig := new(AdminConfig)
+	if err := scfg.Config.UnmarshalKey("admin", adminConfig); err != nil {
+		logger.Fatalf("invalid admin config: %s", err)
+	}
+	return adminConfig
+}
+
+func GetAllocationConfig(scfg *base.SignerConfig) *AllocationConfig {
+	allocationConfig := new(AllocationConfig)
+	if err := scfg.Config.UnmarshalKey("allocation", allocationConfig); err != nil {
+		logger.Fatalf("invalid allocation config: %s", err)
+	}
+	return allocationConfig
+}
+
+func GetApprovalConfig(scfg *base.SignerConfig) *ApprovalConfig {
+	approvalConfig := new(ApprovalConfig)
+	if err := scfg.Config.UnmarshalKey("approval", approvalConfig); err != nil {
+		logger.Fatalf("invalid approval config: %s", err)
+	}
+	return approvalConfig
+}
+
+func GetWebhookConfig(scfg *base.SignerConfig) *webhook.Config {
+	webhookConfig := new(webhook.Config)
+	if err := scfg.Config.UnmarshalKey("webhook", webhookConfig); err != nil {
+		logger.Fatalf("invalid webhook config: %s", err)
+	}
+	return webhookConfig
+}
+
+func GetMetricsConfig(scfg *base.SignerConfig) *metrics.Config {
+	metricsConfig := new(metrics.Config)
+	if err := scfg.Config.UnmarshalKey("metrics", metricsConfig); err != nil {
+		logger.Fatalf("invalid metrics config: %s", err)
+	}
+	return metricsConfig
+}
+
 func GetIpWhiteList(ipList []string) map[string]struct{} {
 	ipWhiteList := make(map[string]struct{})
 	for _, ip := range ipList {
@@ -93,11 +340,52 @@ func GetIpWhiteList(ipList []string) map[string]struct{} {
 	return ipWhiteList
 }
 
+// GetRuleConfig the rules of all rule files, merged in file order
 func GetRuleConfig(scfg *base.SignerConfig) (rules.Rules, error) {
-	_rules := new(rules.Rules)
-	err := json.Unmarshal(scfg.Rule, _rules)
-	if err != nil {
-		return nil, err
+	var _rules rules.Rules
+	for _, file := range scfg.RuleFiles {
+		rs, err := rules.ParseFile(file.Path, file.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.Path, err)
+		}
+		_rules = append(_rules, rs...)
+	}
+	return _rules, nil
+}
+
+func GetPolicyConfig(scfg *base.SignerConfig) *policy.Config {
+	policyConfig := new(policy.Config)
+	if err := scfg.Config.UnmarshalKey("policy", policyConfig); err != nil {
+		logger.Fatalf("invalid policy config: %s", err)
+	}
+	return policyConfig
+}
+
+func GetScriptConfig(scfg *base.SignerConfig) *rules.ScriptConfig {
+	scriptConfig := new(rules.ScriptConfig)
+	if err := scfg.Config.UnmarshalKey("script", scriptConfig); err != nil {
+		logger.Fatalf("invalid script config: %s", err)
+	}
+	if scriptConfig.Dir == "" {
+		scriptConfig.Dir = scfg.RuleDir
+	}
+	return scriptConfig
+}
+
+// LintRules checks the rule files against the rule schema and the configured chains
+func LintRules(scfg *base.SignerConfig, chains map[uint64]*ChainConfig) []*rules.Problem {
+	chainIds := make(map[int64]bool)
+	for chainId := range chains {
+		chainIds[int64(chainId)] = true
+	}
+	var problems []*rules.Problem
+	for _, file := range scfg.RuleFiles {
+		problems = append(problems, rules.Lint(file.Path, file.Data, chainIds)...)
+	}
+	if !rules.HasErrors(problems) && len(scfg.RuleFiles) > 1 {
+		if rs, err := GetRuleConfig(scfg); err == nil {
+			problems = append(problems, rules.LintDuplicates(rs)...)
+		}
 	}
-	return *_rules, nil
+	return problems
 }
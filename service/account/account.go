@@ -33,6 +33,9 @@ var (
 	errPassArgument  = errors.New("account config error, pass must be string")
 	errUseLastPass   = errors.New("account config error, use_last_pass must be bool")
 	errIndexArgument = errors.New("account config error, index must be string, eg: 0-9,256")
+	errPathArgument  = errors.New("account config error, path must be string, eg: m/44'/60'/0'/0/{index}")
+	errSeedPass      = errors.New("account config error, seed_passphrase must be string")
+	errLazy          = errors.New("account config error, lazy must be bool")
 
 	errKeyNull   = errors.New("key field is null")
 	errIndexNull = errors.New("index field is null")
@@ -178,6 +181,33 @@ func checkMnemonicParams(params map[string]interface{}) error {
 	if reflect.TypeOf(params["pass"]).Kind() != reflect.String {
 		return errPassArgument
 	}
+
+	// path 和 seed_passphrase 为选填字段，默认为标准路径和空的 BIP-39 passphrase。
+	if params["path"] == nil || params["path"] == "" {
+		params["path"] = ethutils.DefaultPath
+	}
+	path, ok := params["path"].(string)
+	if !ok {
+		return errPathArgument
+	}
+	if err := ethutils.ValidatePath(path); err != nil {
+		return fmt.Errorf("account config error, %s", err)
+	}
+
+	if params["seed_passphrase"] == nil {
+		params["seed_passphrase"] = ""
+	}
+	if _, ok = params["seed_passphrase"].(string); !ok {
+		return errSeedPass
+	}
+
+	// lazy 为选填字段：为 true 时账户按需派生，不在启动时派生整个 index 范围。
+	if params["lazy"] == nil {
+		params["lazy"] = false
+	}
+	if _, ok = params["lazy"].(bool); !ok {
+		return errLazy
+	}
 	return nil
 }
 
@@ -196,7 +226,10 @@ func (a *_AccountOpt) Crypto() (ICrypto, error) {
 			return nil, err
 		}
 		key := params["key"].(string)
-		pass := passPhrase(KeyStoreTy, params["pass"].(string))
+		pass, err := passPhrase(string(KeyStoreTy), params)
+		if err != nil {
+			return nil, err
+		}
 		return NewKeystore(key, pass)
 	case EvMnemonicTy:
 		params := c.params.(map[string]interface{})
@@ -227,8 +260,11 @@ func (a *_AccountOpt) Crypto() (ICrypto, error) {
 		}
 		key := params["key"].(string)
 		indexRange := params["index"].(string)
-		pass := passPhrase(EncryptedMnemonicTy, params["pass"].(string))
-		return NewEncryptedMnemonic(key, pass, indexRange)
+		pass, err := passPhrase(string(EncryptedMnemonicTy), params)
+		if err != nil {
+			return nil, err
+		}
+		return NewEncryptedMnemonic(key, pass, indexRange, params["path"].(string), params["seed_passphrase"].(string))
 	case PlainMnemonicTy:
 		params := c.params.(map[string]interface{})
 		err := checkMnemonicParams(params)
@@ -237,13 +273,13 @@ func (a *_AccountOpt) Crypto() (ICrypto, error) {
 		}
 		key := params["key"].(string)
 		index := params["index"].(string)
-		return NewPlainMnemonic(key, index)
+		return NewPlainMnemonic(key, index, params["path"].(string), params["seed_passphrase"].(string))
 	case PlainPrivateKeyTy:
 		params := c.params.(map[string]interface{})
-		if _, ok := params["key"]; !ok {
+		key, ok := params["key"].(string)
+		if !ok {
 			return nil, fmt.Errorf("account config error, PlainPrivateKey Type must contains key field")
 		}
-		key := params["key"].(string)
 		return NewPlainPrivateKey(key)
 	default:
 		return nil, fmt.Errorf("unSupported account type, only support Keystore, EvMnemonic, " +
@@ -251,20 +287,6 @@ func (a *_AccountOpt) Crypto() (ICrypto, error) {
 	}
 }
 
-func passPhrase(accountTy _AccountType, pass string) string {
-	if pass == "" {
-		password := getPassPhrase(fmt.Sprintf(
-			"please enter password for [%s] account",
-			accountTy,
-		), false)
-		if password == "" {
-			logger.Fatalf("password does not empty")
-		}
-		pass = password
-	}
-	return pass
-}
-
 func (a *Account) Account() IAccountOpt {
 	return &_AccountOpt{
 		accountTy: a.accountTy,
@@ -321,3 +343,12 @@ func getPassPhrase(text string, confirmation bool) string {
 	}
 	return password
 }
+
+// ZeroKey overwrites the scalar of a private key once it is no longer needed
+func ZeroKey(key *ecdsa.PrivateKey) {
+	words := key.D.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	key.D.SetInt64(0)
+}
@@ -2,12 +2,12 @@ package account
 
 import (
 	"encoding/json"
+	"errors"
 	"evm-signer/pkg/ethutils"
 	"evm-signer/pkg/strutil"
 	"evm-signer/types"
 	"fmt"
 	_keystore "github.com/ethereum/go-ethereum/accounts/keystore"
-	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/google/uuid"
 	"os"
 	"strconv"
@@ -16,6 +16,8 @@ import (
 type encryptedMnemonic struct {
 	mnemonic string
 	password string
+	path     string // the derivation path with the {index} placeholder
+	seedPass string // the BIP-39 passphrase
 	indexMap map[int64]struct{}
 }
 
@@ -28,10 +30,13 @@ type encryptedKeyJSONV3 struct {
 
 const version = 3
 
-func NewEncryptedMnemonic(key, password, indexRange string) (*encryptedMnemonic, error) {
+func NewEncryptedMnemonic(key, password, indexRange, path, seedPass string) (*encryptedMnemonic, error) {
 	if key == "" || password == "" || indexRange == "" {
 		return nil, fmt.Errorf("encrypted mnemonic config error")
 	}
+	if path == "" {
+		path = ethutils.DefaultPath
+	}
 
 	indexMap := make(map[int64]struct{})
 	splitMap, err := strutil.SplitNum(indexRange)
@@ -43,40 +48,61 @@ func NewEncryptedMnemonic(key, password, indexRange string) (*encryptedMnemonic,
 		__index, _ := strconv.ParseInt(_index, 10, 64)
 		indexMap[__index] = struct{}{}
 	}
-	return &encryptedMnemonic{mnemonic: key, password: password, indexMap: indexMap}, nil
+	return &encryptedMnemonic{mnemonic: key, password: password, path: path, seedPass: seedPass, indexMap: indexMap}, nil
 }
 
-func (m *encryptedMnemonic) Decrypt() []*types.Account {
+// Decrypt the mnemonic and derive the accounts of the index range
+func (m *encryptedMnemonic) Decrypt() ([]*types.Account, error) {
 	var accounts []*types.Account
 
-	keyJson, err := os.ReadFile(m.mnemonic)
+	mnemonic, err := m.decryptMnemonic()
 	if err != nil {
-		logger.Fatalf("Failed to read the keyfile at '%s': %v", m.mnemonic, err)
+		return nil, err
 	}
-
-	key := new(encryptedKeyJSONV3)
-	if err = json.Unmarshal(keyJson, key); err != nil {
-		logger.Fatalf("Unmarshal  keyfile at '%s': %v", m.mnemonic, err)
-	}
-
-	keyBytes, _, err := decryptKeyV3(key, m.password)
+	deriver, err := ethutils.NewDeriver(mnemonic, m.seedPass, m.path)
 	if err != nil {
-		utils.Fatalf("Error decrypting key: %v", err)
+		return nil, fmt.Errorf("%w: %v", ErrDerive, err)
 	}
-
 	for k := range m.indexMap {
 		account := &types.Account{
 			Index: k,
 		}
 
-		_key := ethutils.GetAccountFromMnemonic(string(keyBytes), int(k))
+		_key, err := deriver.Derive(int(k))
+		if err != nil {
+			return nil, indexError(k, ErrDerive, err)
+		}
 		account.Address = _key.Address
 		logger.Debugf("index: [%d], address: [%s] \n", k, account.Address.String())
 		account.PriKey = _key.PrivateKey
+		account.Path, account.XPub = deriver.PathOf(int(k)), deriver.XPub()
 
 		accounts = append(accounts, account)
 	}
-	return accounts
+	return accounts, nil
+}
+
+// decryptMnemonic the mnemonic of the key file, checked to be a valid one
+func (m *encryptedMnemonic) decryptMnemonic() (string, error) {
+	keyJson, err := os.ReadFile(m.mnemonic)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrKeyFile, err)
+	}
+
+	key := new(encryptedKeyJSONV3)
+	if err = json.Unmarshal(keyJson, key); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrKeyFile, m.mnemonic, err)
+	}
+
+	keyBytes, _, err := decryptKeyV3(key, m.password)
+	if err != nil {
+		return "", decryptError(m.mnemonic, err)
+	}
+	if err = ethutils.ValidateMnemonic(string(keyBytes)); err != nil {
+		return "", fmt.Errorf("%w: the keyfile at '%s' holds a mnemonic with an unknown word or a wrong checksum",
+			ErrInvalidMnemonic, m.mnemonic)
+	}
+	return string(keyBytes), nil
 }
 
 func decryptKeyV3(keyProtected *encryptedKeyJSONV3, auth string) (keyBytes []byte, keyId []byte, err error) {
@@ -95,6 +121,14 @@ func decryptKeyV3(keyProtected *encryptedKeyJSONV3, auth string) (keyBytes []byt
 	return plainText, keyId, err
 }
 
+// decryptError tells a wrong passphrase from a broken key file
+func decryptError(path string, err error) error {
+	if errors.Is(err, _keystore.ErrDecrypt) {
+		return fmt.Errorf("%w: %v", ErrPassphrase, err)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrKeyFile, path, err)
+}
+
 func (m *encryptedMnemonic) Crypto() error {
 	return fmt.Errorf("unSupport crypto")
 }
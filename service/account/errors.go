@@ -0,0 +1,95 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// the causes of a LoadError, test them with errors.Is
+var (
+	ErrConfig          = errors.New("invalid account config")
+	ErrKeyFile         = errors.New("unreadable key file")
+	ErrNoPassphrase    = errors.New("no passphrase")
+	ErrPassphrase      = errors.New("wrong passphrase")
+	ErrInvalidKey      = errors.New("invalid private key")
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	ErrDerive          = errors.New("derivation failed")
+)
+
+// LoadError an account source which could not be loaded
+type LoadError struct {
+	Source string // the config path, account or account.keys.<index>
+	Type   string // the account type of the source
+	Index  int64  // the account index, -1 when the error is not about one
+	Err    error
+}
+
+func (e *LoadError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[ %s ]", e.Source)
+	if e.Type != "" {
+		fmt.Fprintf(&b, " %s", e.Type)
+	}
+	if e.Index >= 0 {
+		fmt.Fprintf(&b, " index %d", e.Index)
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// LoadErrors every source which failed, so a config with several mistakes is fixed in one go
+type LoadErrors []*LoadError
+
+func (e LoadErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("%d account sources failed to load:", len(e)))
+	for _, err := range e {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// add appends the error of a source, LoadErrors of a nested source are kept as they are
+func (e LoadErrors) add(source string, ty _AccountType, index int64, err error) LoadErrors {
+	var errs LoadErrors
+	if errors.As(err, &errs) {
+		for _, _err := range errs {
+			e = e.add(source, ty, index, _err)
+		}
+		return e
+	}
+	return append(e, loadError(source, ty, index, err))
+}
+
+// err nil when nothing failed
+func (e LoadErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// loadError names the source of err, a LoadError from a loader keeps its index
+func loadError(source string, ty _AccountType, index int64, err error) *LoadError {
+	var le *LoadError
+	if errors.As(err, &le) {
+		if le.Source == "" {
+			le.Source = source
+		}
+		if le.Type == "" {
+			le.Type = string(ty)
+		}
+		return le
+	}
+	return &LoadError{Source: source, Type: string(ty), Index: index, Err: err}
+}
+
+// indexError the error of one derived account, the source is named by the caller
+func indexError(index int64, cause error, err error) *LoadError {
+	return &LoadError{Index: index, Err: fmt.Errorf("%w: %v", cause, err)}
+}
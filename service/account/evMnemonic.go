@@ -1,18 +1,14 @@
 package account
 
 import (
-	"crypto/ecdsa"
 	"evm-signer/pkg/ethutils"
 	"evm-signer/types"
 	"fmt"
-	_keystore "github.com/ethereum/go-ethereum/accounts/keystore"
-	"github.com/ethereum/go-ethereum/cmd/utils"
-	"github.com/ethereum/go-ethereum/common"
-	"os"
 	"sort"
 )
 
 type evMnemonic struct {
+	name string // the account source, RootSource when empty
 	keys map[int64]interface{}
 }
 
@@ -23,84 +19,89 @@ func NewEvMnemonic(keys map[int64]interface{}) (*evMnemonic, error) {
 	return &evMnemonic{keys: keys}, nil
 }
 
-func (em *evMnemonic) Decrypt() []*types.Account {
+// Decrypt loads every key, the errors of all failing keys are returned together
+func (em *evMnemonic) Decrypt() ([]*types.Account, error) {
 	var accounts []*types.Account
+	var errs LoadErrors
 	lastPass := ""
 
+	name := em.name
+	if name == "" {
+		name = RootSource
+	}
 	keys := em.sortKeys()
 	for index, k := range keys {
-		subKeyMap := em.keys[int64(k)].(map[string]interface{})
-		account := &types.Account{
-			Index: int64(k),
+		source := fmt.Sprintf("%s.keys.%d", name, k)
+		subKeyMap, ok := em.keys[int64(k)].(map[string]interface{})
+		if !ok {
+			errs = errs.add(source, "", int64(k), fmt.Errorf("%w: must be a map", ErrConfig))
+			continue
 		}
+		subKeyType, _ := subKeyMap["type"].(string)
 
-		var _address common.Address
-		var _priKey *ecdsa.PrivateKey
-		// check type
-		subKeyType := subKeyMap["type"].(string)
-		switch _AccountType(subKeyType) {
-		case PlainPrivateKeyTy:
-			err := checkKeystoreParams(subKeyMap)
-			if err != nil {
-				logger.Fatalf("subKey config for acount index %d error: %s", k, err)
-			}
-
-			_account := ethutils.GetAccountFromPStr(subKeyMap["key"].(string))
-			if _account == nil {
-				logger.Errorf("plain privateKey is invalidate")
-				return nil
-			}
-			_address = _account.Address
-			_priKey = _account.PrivateKey
-		case KeyStoreTy:
-			err := checkKeystoreParams(subKeyMap)
-			if err != nil {
-				logger.Fatalf("subKey config for account index %d error: %s", k, err)
-			}
-
-			_key := subKeyMap["key"].(string)
-			keyJson, err := os.ReadFile(_key)
-			if err != nil {
-				logger.Fatalf("Failed to read the keyfile at '%s': %v", _key, err)
-			}
-
-			pass := subKeyMap["pass"].(string)
-			lastPass, pass = resetPass(lastPass, pass, index, subKeyMap)
-			__keystore, err := _keystore.DecryptKey(keyJson, pass)
-			if err != nil {
-				utils.Fatalf("Error decrypting key: %v", err)
-			}
-			_address = __keystore.Address
-			_priKey = __keystore.PrivateKey
-		case PlainMnemonicTy:
-			err := checkMnemonicParams(subKeyMap)
-			if err != nil {
-				logger.Fatalf("subKey config for account index %d error: %s", k, err)
-			}
-			mnemonic := subKeyMap["key"].(string)
-			mnemonicIndex := subKeyMap["index"].(string)
-			// PlainMnemonic in EvMnemonic uses the first index from the range
-			pm, err := NewPlainMnemonic(mnemonic, mnemonicIndex)
-			if err != nil {
-				logger.Fatalf("create plain mnemonic error: %s", err)
-			}
-			pmAccounts := pm.Decrypt()
-			if len(pmAccounts) == 0 {
-				logger.Fatalf("plain mnemonic decrypt failed for account index %d", k)
-			}
-			// Use the first account from the mnemonic
-			_address = pmAccounts[0].Address
-			_priKey = pmAccounts[0].PriKey
-		default:
-			logger.Fatalf("%s type unsupported", subKeyType)
+		var account *types.Account
+		var err error
+		lastPass, account, err = em.decryptKey(k, index, lastPass, subKeyMap)
+		if err != nil {
+			errs = errs.add(source, _AccountType(subKeyType), int64(k), err)
+			continue
 		}
 
-		logger.Infof("account type: [%s], index: [%d], address: [%s]", subKeyType, k, _address)
-		account.Address = _address
-		account.PriKey = _priKey
+		logger.Infof("account type: [%s], index: [%d], address: [%s]", subKeyType, k, account.Address)
+		account.Index = int64(k)
+		account.Source = subKeyType
 		accounts = append(accounts, account)
 	}
-	return accounts
+	return accounts, errs.err()
+}
+
+// decryptKey loads the key k at the position of the sorted keys
+func (em *evMnemonic) decryptKey(k, position int, lastPass string, subKeyMap map[string]interface{}) (string, *types.Account, error) {
+	subKeyType, _ := subKeyMap["type"].(string)
+	switch _AccountType(subKeyType) {
+	case PlainPrivateKeyTy:
+		if err := checkKeystoreParams(subKeyMap); err != nil {
+			return lastPass, nil, fmt.Errorf("%w: %v", ErrConfig, err)
+		}
+		_account := ethutils.GetAccountFromPStr(subKeyMap["key"].(string))
+		if _account == nil {
+			return lastPass, nil, fmt.Errorf("%w: not a 32 byte hex key", ErrInvalidKey)
+		}
+		return lastPass, &types.Account{Address: _account.Address, PriKey: _account.PrivateKey}, nil
+	case KeyStoreTy:
+		if err := checkKeystoreParams(subKeyMap); err != nil {
+			return lastPass, nil, fmt.Errorf("%w: %v", ErrConfig, err)
+		}
+		lastPass, pass, err := resetPass(lastPass, position, k, subKeyMap)
+		if err != nil {
+			return lastPass, nil, fmt.Errorf("%w: %v", ErrNoPassphrase, err)
+		}
+		accounts, err := (&keystore{path: subKeyMap["key"].(string), pass: pass}).Decrypt()
+		if err != nil {
+			return lastPass, nil, err
+		}
+		return lastPass, accounts[0], nil
+	case PlainMnemonicTy:
+		if err := checkMnemonicParams(subKeyMap); err != nil {
+			return lastPass, nil, fmt.Errorf("%w: %v", ErrConfig, err)
+		}
+		// PlainMnemonic in EvMnemonic uses the first index from the range
+		pm, err := NewPlainMnemonic(subKeyMap["key"].(string), subKeyMap["index"].(string),
+			subKeyMap["path"].(string), subKeyMap["seed_passphrase"].(string))
+		if err != nil {
+			return lastPass, nil, err
+		}
+		pmAccounts, err := pm.Decrypt()
+		if err != nil {
+			return lastPass, nil, err
+		}
+		if len(pmAccounts) == 0 {
+			return lastPass, nil, fmt.Errorf("%w: empty index range", ErrConfig)
+		}
+		return lastPass, pmAccounts[0], nil
+	default:
+		return lastPass, nil, fmt.Errorf("%w: %s type unsupported", ErrConfig, subKeyType)
+	}
 }
 
 func (em *evMnemonic) sortKeys() []int {
@@ -112,24 +113,23 @@ func (em *evMnemonic) sortKeys() []int {
 	return keys
 }
 
-func resetPass(lastPass, pass string, k int, subKeyMap map[string]interface{}) (string, string) {
+// resetPass the passphrase of a keystore sub key, with use_last_pass a key without its own passphrase
+// or passphrase source reuses the one of the previous key
+func resetPass(lastPass string, position, k int, subKeyMap map[string]interface{}) (string, string, error) {
 	if _, ok := subKeyMap["use_last_pass"]; !ok {
 		subKeyMap["use_last_pass"] = false
 	}
 
 	isUseLastPass := subKeyMap["use_last_pass"].(bool)
-	if isUseLastPass {
-		if k == 0 {
-			pass = passPhrase(KeyStoreTy, pass)
-			lastPass = pass
-		} else if k != 0 && pass == "" {
-			pass = lastPass
-		}
-	} else {
-		pass = passPhrase(KeyStoreTy, pass)
-		lastPass = pass
+	if isUseLastPass && position != 0 && !hasPassSource(subKeyMap) {
+		wipePass(subKeyMap)
+		return lastPass, lastPass, nil
+	}
+	pass, err := passPhrase(fmt.Sprintf("%s index %d", KeyStoreTy, k), subKeyMap)
+	if err != nil {
+		return lastPass, "", err
 	}
-	return lastPass, pass
+	return pass, pass, nil
 }
 
 func (em *evMnemonic) Crypto() error {
@@ -0,0 +1,119 @@
+package account
+
+import (
+	"crypto/ecdsa"
+	"evm-signer/pkg/ethutils"
+	"evm-signer/pkg/strutil"
+	"evm-signer/types"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"sort"
+	"sync"
+)
+
+// HDWallet the accounts of a lazy mnemonic source, derived on demand from the key above the {index} element. Only their
+// addresses are cached, a private key is derived again for every signing, so a huge index range costs neither
+// startup time nor memory for the accounts never used, and the used ones keep no key in memory
+type HDWallet struct {
+	Name string // the account source
+	Type _AccountType
+
+	deriver *ethutils.Deriver
+	ranges  strutil.Ranges
+	lock    sync.Mutex
+	cache   map[int64]*types.Account
+}
+
+func newHDWallet(name string, ty _AccountType, mnemonic, seedPass, path, indexRange string) (*HDWallet, error) {
+	ranges, err := strutil.ParseRanges(indexRange)
+	if err != nil || len(ranges) == 0 {
+		return nil, fmt.Errorf("%w: index config error", ErrConfig)
+	}
+	deriver, err := ethutils.NewDeriver(mnemonic, seedPass, path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDerive, err)
+	}
+	return &HDWallet{
+		Name:    name,
+		Type:    ty,
+		deriver: deriver,
+		ranges:  ranges,
+		cache:   make(map[int64]*types.Account),
+	}, nil
+}
+
+// Ranges the index range of the wallet
+func (w *HDWallet) Ranges() strutil.Ranges {
+	return w.ranges
+}
+
+// XPub the extended public key of the key above the {index} element, see ethutils.XPubPath
+func (w *HDWallet) XPub() string {
+	return w.deriver.XPub()
+}
+
+// Path the derivation path with the {index} placeholder
+func (w *HDWallet) Path() string {
+	return w.deriver.Path()
+}
+
+// Account the account of an index of the range without its private key, derived by the first call
+func (w *HDWallet) Account(index int64) (*types.Account, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _account, ok := w.cache[index]; ok {
+		return _account, nil
+	}
+	_account, err := w.Derive(index)
+	if err != nil {
+		return nil, err
+	}
+	w.cache[index] = _account
+	return _account, nil
+}
+
+// Derive the account of an index of the range without its private key, without caching it
+func (w *HDWallet) Derive(index int64) (*types.Account, error) {
+	key, err := w.PrivateKey(index)
+	if err != nil {
+		return nil, err
+	}
+	publicKey := key.PublicKey
+	ZeroKey(key)
+	return &types.Account{
+		Index:   index,
+		Address: crypto.PubkeyToAddress(publicKey),
+		PubKey:  &publicKey,
+		Source:  string(w.Type),
+		Name:    w.Name,
+		Path:    w.deriver.PathOf(int(index)),
+		XPub:    w.deriver.XPub(),
+	}, nil
+}
+
+// PrivateKey derives the private key of an index of the range, it is never cached, the caller zeroes it
+// once signed
+func (w *HDWallet) PrivateKey(index int64) (*ecdsa.PrivateKey, error) {
+	if !w.ranges.Contains(index) {
+		return nil, fmt.Errorf("index %d is out of the index range of [ %s ]", index, w.Name)
+	}
+	key, err := w.deriver.Derive(int(index))
+	if err != nil {
+		return nil, indexError(index, ErrDerive, err)
+	}
+	return key.PrivateKey, nil
+}
+
+// Cached the accounts derived so far by index
+func (w *HDWallet) Cached() []*types.Account {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	accounts := make([]*types.Account, 0, len(w.cache))
+	for _, _account := range w.cache {
+		accounts = append(accounts, _account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Index < accounts[j].Index
+	})
+	return accounts
+}
@@ -26,7 +26,7 @@ type (
 	}
 
 	ICrypto interface {
-		Decrypt() []*types.Account
+		Decrypt() ([]*types.Account, error)
 		Crypto() error
 	}
 )
@@ -0,0 +1,431 @@
+package account
+
+import (
+	"encoding/json"
+	"evm-signer/pkg/ethutils"
+	"evm-signer/types"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const RootSource = "account" // the top level key of the account config, the name of its only source
+
+var sourceName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Source a named account source, an entry of the accounts list or the single account block
+type Source struct {
+	Name         string
+	Params       map[string]interface{} // the account config of the source
+	Aliases      map[string]int64       // alias: account index in the source
+	Restriction  *Restriction           // of every account of the source, nil allows all
+	Restrictions map[int64]*Restriction // of single accounts by index, replacing the one of the source
+	Allocate     bool                   // a mnemonic source which hands out deposit addresses
+}
+
+// Key a key which needs a passphrase, a Keystore or an EncryptedMnemonic source or a Keystore key of an
+// EvMnemonic source. Keys can be locked and unlocked at runtime
+type Key struct {
+	Name      string // the account source of the key
+	Source    string // the config path of the key, <name> or <name>.keys.<index>
+	Type      _AccountType
+	Index     int64            // the account index of a keystore
+	Addresses []common.Address // known while locked for keystores, else after the first unlock, kept by the caller
+
+	path        string
+	indexRange  string
+	hdPath      string
+	seedPass    string
+	useLastPass bool
+	lazy        bool                   // an EncryptedMnemonic unlocks into an HDWallet
+	params      map[string]interface{} // the config map, its passphrase fields are dropped by the first unlock
+	passSource  map[string]interface{} // the passphrase source, read again by a runtime unlock
+}
+
+// Keyring the keys of the account sources and their plain accounts, which need no passphrase
+type Keyring struct {
+	Sources  []*Source
+	Keys     []*Key
+	Accounts []*types.Account
+	Wallets  []*HDWallet // the lazy PlainMnemonic sources
+}
+
+// Unlocked what the unlock of a key decrypted, its accounts or the wallet of a lazy EncryptedMnemonic
+type Unlocked struct {
+	Accounts []*types.Account
+	Wallet   *HDWallet
+}
+
+// NewKeyring splits the account sources into keys and plain accounts, only the plain accounts are loaded.
+// The error is a LoadErrors naming every failing source
+func NewKeyring(sources ...*Source) (*Keyring, error) {
+	kr := &Keyring{Sources: sources}
+	errs := checkSources(sources)
+	if len(errs) == 0 {
+		for _, source := range sources {
+			errs = kr.load(source, errs)
+		}
+	}
+	if len(errs) == 0 {
+		errs = kr.checkAddresses()
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// load adds the keys and the plain accounts of a source
+func (kr *Keyring) load(source *Source, errs LoadErrors) LoadErrors {
+	name, params := source.Name, source.Params
+	accountTy, _ := params["type"].(string)
+	var keys []*Key
+	var accounts []*types.Account
+	switch _AccountType(accountTy) {
+	case KeyStoreTy:
+		if err := checkKeystoreParams(params); err != nil {
+			return errs.add(name, KeyStoreTy, -1, fmt.Errorf("%w: %v", ErrConfig, err))
+		}
+		keys = append(keys, newKey(name, name, KeyStoreTy, 0, params))
+	case EncryptedMnemonicTy:
+		if err := checkMnemonicParams(params); err != nil {
+			return errs.add(name, EncryptedMnemonicTy, -1, fmt.Errorf("%w: %v", ErrConfig, err))
+		}
+		keys = append(keys, newKey(name, name, EncryptedMnemonicTy, 0, params))
+	case EvMnemonicTy:
+		subKeys, ok := params["keys"].(map[string]interface{})
+		if !ok || len(subKeys) == 0 {
+			return errs.add(name, EvMnemonicTy, -1, fmt.Errorf("%w: EvMnemonic Type must contains keys field", ErrConfig))
+		}
+		plain := make(map[int64]interface{})
+		for _index, val := range subKeys {
+			index, err := strconv.ParseInt(_index, 10, 64)
+			if err != nil {
+				errs = errs.add(name+".keys."+_index, "", -1, fmt.Errorf("%w: EvMnemonic keys index must be number", ErrConfig))
+				continue
+			}
+			keySource := fmt.Sprintf("%s.keys.%d", name, index)
+			subKeyMap, ok := val.(map[string]interface{})
+			if !ok || subKeyMap["type"] != string(KeyStoreTy) {
+				plain[index] = val
+				continue
+			}
+			if err = checkKeystoreParams(subKeyMap); err != nil {
+				errs = errs.add(keySource, KeyStoreTy, index, fmt.Errorf("%w: %v", ErrConfig, err))
+				continue
+			}
+			keys = append(keys, newKey(name, keySource, KeyStoreTy, index, subKeyMap))
+		}
+		sortKeys(keys)
+		if len(plain) != 0 {
+			em, _ := NewEvMnemonic(plain)
+			em.name = name
+			_accounts, err := em.Decrypt()
+			if err != nil {
+				errs = errs.add(name, EvMnemonicTy, -1, err)
+			}
+			accounts = _accounts
+		}
+	case PlainMnemonicTy:
+		if lazy, _ := params["lazy"].(bool); lazy {
+			wallet, err := newLazyMnemonic(name, params)
+			if err != nil {
+				return errs.add(name, PlainMnemonicTy, -1, err)
+			}
+			kr.Wallets = append(kr.Wallets, wallet)
+			return errs
+		}
+		fallthrough
+	default:
+		crypto, err := NewAccount(accountTy, params).Account().Crypto()
+		if err != nil {
+			return errs.add(name, _AccountType(accountTy), -1, fmt.Errorf("%w: %v", ErrConfig, err))
+		}
+		if accounts, err = crypto.Decrypt(); err != nil {
+			return errs.add(name, _AccountType(accountTy), -1, err)
+		}
+		for _, _account := range accounts {
+			_account.Source = accountTy
+		}
+	}
+
+	for _, _account := range accounts {
+		_account.Name = name
+	}
+	kr.Keys = append(kr.Keys, keys...)
+	kr.Accounts = append(kr.Accounts, accounts...)
+	return errs
+}
+
+// newLazyMnemonic the wallet of a lazy PlainMnemonic source
+func newLazyMnemonic(name string, params map[string]interface{}) (*HDWallet, error) {
+	if err := checkMnemonicParams(params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+	mnemonic := params["key"].(string)
+	if err := ethutils.ValidateMnemonic(mnemonic); err != nil {
+		return nil, fmt.Errorf("%w: unknown word or wrong checksum", ErrInvalidMnemonic)
+	}
+	return newHDWallet(name, PlainMnemonicTy, mnemonic, params["seed_passphrase"].(string),
+		params["path"].(string), params["index"].(string))
+}
+
+// checkSources the names of the sources and their aliases are unique, an alias can't be read as an
+// address or a source:index reference
+func checkSources(sources []*Source) LoadErrors {
+	var errs LoadErrors
+	names := make(map[string]struct{})
+	aliases := make(map[string]string)
+	for _, source := range sources {
+		name := strings.ToLower(source.Name)
+		if !sourceName.MatchString(source.Name) {
+			errs = errs.add(source.Name, "", -1, fmt.Errorf("%w: source name must be letters, digits, _ or -", ErrConfig))
+			continue
+		}
+		if _, ok := names[name]; ok {
+			errs = errs.add(source.Name, "", -1, fmt.Errorf("%w: duplicate source name", ErrConfig))
+			continue
+		}
+		names[name] = struct{}{}
+
+		for alias, index := range source.Aliases {
+			_alias := strings.ToLower(alias)
+			switch {
+			case !sourceName.MatchString(alias) || common.IsHexAddress(alias):
+				errs = errs.add(source.Name, "", index, fmt.Errorf("%w: alias [ %s ] must be letters, digits, _ or - "+
+					"and not an address", ErrConfig, alias))
+			case index < 0:
+				errs = errs.add(source.Name, "", index, fmt.Errorf("%w: alias [ %s ] index must be >= 0", ErrConfig, alias))
+			case aliases[_alias] != "":
+				errs = errs.add(source.Name, "", index, fmt.Errorf("%w: alias [ %s ] is also used by [ %s ]",
+					ErrConfig, alias, aliases[_alias]))
+			default:
+				aliases[_alias] = source.Name
+			}
+		}
+	}
+	return errs
+}
+
+// checkAddresses an address known before the unlock belongs to one source only
+func (kr *Keyring) checkAddresses() LoadErrors {
+	var errs LoadErrors
+	owner := make(map[common.Address]string)
+	check := func(address common.Address, source string, ty _AccountType, index int64) {
+		if other, ok := owner[address]; ok {
+			errs = errs.add(source, ty, index, fmt.Errorf("%w: address %s is also loaded by [ %s ]", ErrConfig, address.Hex(), other))
+			return
+		}
+		owner[address] = source
+	}
+	for _, _account := range kr.Accounts {
+		check(_account.Address, _account.Name, _AccountType(_account.Source), _account.Index)
+	}
+	for _, key := range kr.Keys {
+		for _, address := range key.Addresses {
+			check(address, key.Source, key.Type, key.Index)
+		}
+	}
+	return errs
+}
+
+// Key the key of a source, nil when there is none
+func (kr *Keyring) Key(source string) *Key {
+	for _, key := range kr.Keys {
+		if key.Source == source {
+			return key
+		}
+	}
+	return nil
+}
+
+// UnlockAll unlocks every key at startup, the passphrases are read from the config, its sources or the prompt.
+// A failing key doesn't stop the others, the error is a LoadErrors naming all of them
+func (kr *Keyring) UnlockAll() (map[*Key]*Unlocked, error) {
+	unlocked := make(map[*Key]*Unlocked)
+	var errs LoadErrors
+	lastPass, lastName := "", ""
+	for _, key := range kr.Keys {
+		var pass string
+		var err error
+		// use_last_pass reuses the passphrase of the previous key of the same source
+		if key.useLastPass && key.Name == lastName && !hasPassSource(key.params) {
+			wipePass(key.params)
+			pass = lastPass
+		} else if pass, err = passPhrase(key.label(), key.params); err != nil {
+			errs = append(errs, key.loadError(fmt.Errorf("%w: %v", ErrNoPassphrase, err)))
+			continue
+		}
+		lastPass, lastName = pass, key.Name
+		_unlocked, err := key.Unlock(pass)
+		if err != nil {
+			errs = append(errs, err.(*LoadError))
+			continue
+		}
+		unlocked[key] = _unlocked
+		key.Addresses = key.Addresses[:0]
+		for _, _account := range _unlocked.Accounts {
+			key.Addresses = append(key.Addresses, _account.Address)
+		}
+	}
+	return unlocked, errs.err()
+}
+
+// Forget drops the passphrases of the config, the keys stay locked until a runtime unlock
+func (kr *Keyring) Forget() {
+	for _, key := range kr.Keys {
+		wipePass(key.params)
+	}
+}
+
+// CanUnlock whether a runtime unlock can read the passphrase from a configured source, a pass_fd is read
+// once at startup and can't be read again
+func (k *Key) CanUnlock() bool {
+	_, fd := k.passSource[passFdField]
+	return len(k.passSource) != 0 && !fd
+}
+
+// Unlock decrypts the key, an empty passphrase is read from the configured source.
+// The error is a *LoadError, a wrong passphrase is never fatal
+func (k *Key) Unlock(pass string) (*Unlocked, error) {
+	unlocked, err := k.unlock(pass)
+	if err != nil {
+		return nil, k.loadError(err)
+	}
+	return unlocked, nil
+}
+
+func (k *Key) unlock(pass string) (*Unlocked, error) {
+	if pass == "" {
+		if _, ok := k.passSource[passFdField]; ok {
+			return nil, fmt.Errorf("%w: pass_fd can only be used at startup, unlock with the passphrase", ErrNoPassphrase)
+		}
+		source, _pass, err := readPassSource(k.passSource)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNoPassphrase, err)
+		}
+		if source == "" {
+			return nil, fmt.Errorf("%w: passphrase is required, no passphrase source configured", ErrNoPassphrase)
+		}
+		if _pass == "" {
+			return nil, fmt.Errorf("%w: %s is empty", ErrNoPassphrase, source)
+		}
+		pass = _pass
+	}
+
+	var accounts []*types.Account
+	switch k.Type {
+	case KeyStoreTy:
+		ks, err := NewKeystore(k.path, pass)
+		if err != nil {
+			return nil, err
+		}
+		if accounts, err = ks.Decrypt(); err != nil {
+			return nil, err
+		}
+		for _, _account := range accounts {
+			_account.Index = k.Index
+		}
+	case EncryptedMnemonicTy:
+		if k.lazy {
+			mnemonic, err := (&encryptedMnemonic{mnemonic: k.path, password: pass}).decryptMnemonic()
+			if err != nil {
+				return nil, err
+			}
+			wallet, err := newHDWallet(k.Name, EncryptedMnemonicTy, mnemonic, k.seedPass, k.hdPath, k.indexRange)
+			if err != nil {
+				return nil, err
+			}
+			return &Unlocked{Accounts: []*types.Account{}, Wallet: wallet}, nil
+		}
+		em, err := NewEncryptedMnemonic(k.path, pass, k.indexRange, k.hdPath, k.seedPass)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+		}
+		if accounts, err = em.Decrypt(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, _account := range accounts {
+		_account.Source = string(k.Type)
+		_account.Name = k.Name
+	}
+	return &Unlocked{Accounts: accounts}, nil
+}
+
+// Root whether the key is a whole source rather than a key of an EvMnemonic source
+func (k *Key) Root() bool {
+	return k.Source == k.Name
+}
+
+// loadError names the key in err, the index of a root key is left out
+func (k *Key) loadError(err error) *LoadError {
+	index := k.Index
+	if k.Root() {
+		index = -1
+	}
+	return loadError(k.Source, k.Type, index, err)
+}
+
+func (k *Key) label() string {
+	label := fmt.Sprintf("%s index %d", k.Type, k.Index)
+	if k.Root() {
+		label = string(k.Type)
+	}
+	if k.Name != RootSource {
+		label = fmt.Sprintf("%s %s", k.Name, label)
+	}
+	return label
+}
+
+func newKey(name, source string, ty _AccountType, index int64, params map[string]interface{}) *Key {
+	key := &Key{
+		Name:       name,
+		Source:     source,
+		Type:       ty,
+		Index:      index,
+		path:       params["key"].(string),
+		params:     params,
+		passSource: make(map[string]interface{}),
+	}
+	key.indexRange, _ = params["index"].(string)
+	key.hdPath, _ = params["path"].(string)
+	key.seedPass, _ = params["seed_passphrase"].(string)
+	key.useLastPass, _ = params["use_last_pass"].(bool)
+	key.lazy, _ = params["lazy"].(bool)
+	for _, field := range passSourceFields {
+		if value, ok := params[field]; ok && value != nil && value != "" {
+			key.passSource[field] = value
+		}
+	}
+	if ty == KeyStoreTy {
+		if address, ok := keystoreAddress(key.path); ok {
+			key.Addresses = []common.Address{address}
+		}
+	}
+	return key
+}
+
+// keystoreAddress the address a keystore file declares, readable without the passphrase
+func keystoreAddress(path string) (common.Address, bool) {
+	keyJson, err := os.ReadFile(path)
+	if err != nil {
+		return common.Address{}, false
+	}
+	key := new(encryptedKeyJSONV3)
+	if err = json.Unmarshal(keyJson, key); err != nil || !common.IsHexAddress(key.Address) {
+		return common.Address{}, false
+	}
+	return common.HexToAddress(key.Address), true
+}
+
+func sortKeys(keys []*Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Index < keys[j].Index
+	})
+}
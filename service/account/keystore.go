@@ -4,7 +4,6 @@ import (
 	"evm-signer/types"
 	"fmt"
 	_keystore "github.com/ethereum/go-ethereum/accounts/keystore"
-	"github.com/ethereum/go-ethereum/cmd/utils"
 	"os"
 )
 
@@ -21,15 +20,15 @@ func NewKeystore(path, pass string) (*keystore, error) {
 	return &keystore{path: path, pass: pass}, nil
 }
 
-func (k *keystore) Decrypt() []*types.Account {
+func (k *keystore) Decrypt() ([]*types.Account, error) {
 	keyJson, err := os.ReadFile(k.path)
 	if err != nil {
-		logger.Fatalf("Failed to read the keyfile at '%s': %v", k.path, err)
+		return nil, fmt.Errorf("%w: %v", ErrKeyFile, err)
 	}
 
 	key, err := _keystore.DecryptKey(keyJson, k.pass)
 	if err != nil {
-		utils.Fatalf("Error decrypting key: %v", err)
+		return nil, decryptError(k.path, err)
 	}
 
 	logger.Infof("address: [%s]", key.Address.String())
@@ -39,7 +38,7 @@ func (k *keystore) Decrypt() []*types.Account {
 		PriKey:  key.PrivateKey,
 	}
 	accounts = append(accounts, account)
-	return accounts
+	return accounts, nil
 }
 
 func (k *keystore) Crypto() error {
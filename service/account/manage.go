@@ -0,0 +1,133 @@
+package account
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"evm-signer/pkg/ethutils"
+	"fmt"
+	_keystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WriteKeystore encrypts the private key into a keystore file with the standard scrypt parameters.
+// A directory gets a file named like geth does, an existing file is never overwritten
+func WriteKeystore(path string, priKey *ecdsa.PrivateKey, pass string) (string, common.Address, error) {
+	address := crypto.PubkeyToAddress(priKey.PublicKey)
+	if info, err := os.Stat(path); err == nil && info.IsDir() || strings.HasSuffix(path, string(os.PathSeparator)) {
+		path = filepath.Join(path, keystoreFileName(address))
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", address, err
+	}
+	key := &_keystore.Key{Id: id, Address: address, PrivateKey: priKey}
+	keyJson, err := _keystore.EncryptKey(key, pass, _keystore.StandardScryptN, _keystore.StandardScryptP)
+	if err != nil {
+		return "", address, err
+	}
+	return path, address, writeKeyFile(path, keyJson, false)
+}
+
+// WriteEncryptedMnemonic seals a valid BIP-39 mnemonic in the v3 keystore format an EncryptedMnemonic account
+// reads, scryptN and scryptP are the scrypt cost parameters. An existing file is never overwritten
+func WriteEncryptedMnemonic(path, mnemonic, pass string, scryptN, scryptP int) error {
+	if err := ethutils.ValidateMnemonic(mnemonic); err != nil {
+		return fmt.Errorf("%w: unknown word or wrong checksum", ErrInvalidMnemonic)
+	}
+	if scryptN <= 1 || scryptN&(scryptN-1) != 0 || scryptP <= 0 {
+		return fmt.Errorf("scrypt n must be a power of 2 and p positive, got n=%d p=%d", scryptN, scryptP)
+	}
+
+	cryptoJSON, err := _keystore.EncryptDataV3([]byte(mnemonic), []byte(pass), scryptN, scryptP)
+	if err != nil {
+		return err
+	}
+	keyJson, err := json.Marshal(&encryptedKeyJSONV3{
+		Crypto:  cryptoJSON,
+		Id:      uuid.NewString(),
+		Version: version,
+	})
+	if err != nil {
+		return err
+	}
+	return writeKeyFile(path, keyJson, false)
+}
+
+// ChangePassword re-encrypts a keystore or an encrypted mnemonic file with a new passphrase
+func ChangePassword(path, oldPass, newPass string) error {
+	keyJson, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	key := new(encryptedKeyJSONV3)
+	if err = json.Unmarshal(keyJson, key); err != nil {
+		return fmt.Errorf("unmarshal keyfile at '%s': %v", path, err)
+	}
+	data, _, err := decryptKeyV3(key, oldPass)
+	if err != nil {
+		return fmt.Errorf("error decrypting key: %v", err)
+	}
+
+	key.Crypto, err = _keystore.EncryptDataV3(data, []byte(newPass), _keystore.StandardScryptN, _keystore.StandardScryptP)
+	if err != nil {
+		return err
+	}
+	keyJson, err = json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return writeKeyFile(path, keyJson, true)
+}
+
+// writeKeyFile writes the file readable by the owner only, a replaced file is swapped in by a rename
+func writeKeyFile(path string, data []byte, replace bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if !replace {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err = f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// keystoreFileName the file name geth gives a keystore, UTC--<created at>--<address>
+func keystoreFileName(address common.Address) string {
+	ts := time.Now().UTC()
+	return fmt.Sprintf("UTC--%s--%s", ts.Format("2006-01-02T15-04-05.000000000Z"), strings.ToLower(address.Hex()[2:]))
+}
+
+// PromptPassphrase asks for a passphrase on the terminal, empty when none was given or the repeat differs
+func PromptPassphrase(text string, confirmation bool) string {
+	return getPassPhrase(text, confirmation)
+}
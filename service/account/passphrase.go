@@ -0,0 +1,206 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the passphrase fields of an account config, pass is the passphrase itself,
+// the others reference where to read it from
+const (
+	passField     = "pass"
+	passFileField = "pass_file" // a file holding the passphrase, eg. a mounted secret
+	passEnvField  = "pass_env"  // the name of an environment variable
+	passFdField   = "pass_fd"   // an inherited file descriptor, read to EOF
+	passCmdField  = "pass_cmd"  // a helper command printing the passphrase, a list of args or a bare command
+
+	passCmdTimeout = 30 * time.Second
+)
+
+var passSourceFields = []string{passFileField, passEnvField, passFdField, passCmdField}
+
+var (
+	// a descriptor can only be read once, it serves one account
+	fdReadLock sync.Mutex
+	fdRead     = make(map[int]bool)
+)
+
+// passPhrase resolves the passphrase of an account config: pass, else the configured source,
+// else the interactive prompt. The passphrase fields are removed from params afterwards
+func passPhrase(account string, params map[string]interface{}) (string, error) {
+	defer wipePass(params)
+
+	if pass, _ := params[passField].(string); pass != "" {
+		return pass, nil
+	}
+
+	source, pass, err := readPassSource(params)
+	if err == nil && source != "" {
+		if pass != "" {
+			logger.Infof("read the passphrase of [%s] account from %s", account, source)
+			return pass, nil
+		}
+		err = fmt.Errorf("%s is empty", source)
+	}
+	if err != nil {
+		logger.Warnf("read the passphrase of [%s] account error: %s, falling back to the prompt", account, err)
+	}
+
+	password := getPassPhrase(fmt.Sprintf("please enter password for [%s] account", account), false)
+	if password == "" {
+		if err != nil {
+			return "", fmt.Errorf("no passphrase for [%s] account: %s", account, err)
+		}
+		return "", fmt.Errorf("no passphrase for [%s] account, set one of pass_file, pass_env, pass_fd "+
+			"or pass_cmd when there is no terminal", account)
+	}
+	return password, nil
+}
+
+// hasPassSource whether the config sets a passphrase or a passphrase source
+func hasPassSource(params map[string]interface{}) bool {
+	for _, field := range append([]string{passField}, passSourceFields...) {
+		if value, ok := params[field]; ok && value != nil && value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// wipePass drops the passphrase fields, params is the map of the loaded config
+func wipePass(params map[string]interface{}) {
+	delete(params, passField)
+	for _, field := range passSourceFields {
+		delete(params, field)
+	}
+}
+
+// readPassSource reads the passphrase from the configured source, an empty source means none is configured
+func readPassSource(params map[string]interface{}) (string, string, error) {
+	var fields []string
+	for _, field := range passSourceFields {
+		if value, ok := params[field]; ok && value != nil && value != "" {
+			fields = append(fields, field)
+		}
+	}
+	switch len(fields) {
+	case 0:
+		return "", "", nil
+	case 1:
+	default:
+		return "", "", fmt.Errorf("only one of %s can be set", strings.Join(fields, ", "))
+	}
+
+	value := params[fields[0]]
+	switch fields[0] {
+	case passFileField:
+		file := fmt.Sprint(value)
+		source := fmt.Sprintf("file %s", file)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return source, "", err
+		}
+		return source, trimPass(data), nil
+	case passEnvField:
+		name := fmt.Sprint(value)
+		source := fmt.Sprintf("environment variable %s", name)
+		pass, ok := os.LookupEnv(name)
+		if !ok {
+			return source, "", fmt.Errorf("%s is not set", source)
+		}
+		return source, trimPass([]byte(pass)), nil
+	case passFdField:
+		fd, err := strconv.Atoi(fmt.Sprint(value))
+		if err != nil || fd < 0 {
+			return "", "", fmt.Errorf("pass_fd must be a file descriptor number, got %v", value)
+		}
+		source := fmt.Sprintf("file descriptor %d", fd)
+		pass, err := readPassFd(fd)
+		return source, pass, err
+	default:
+		args, err := passCmdArgs(value)
+		if err != nil {
+			return "", "", err
+		}
+		source := fmt.Sprintf("command %s", args[0])
+		pass, err := runPassCmd(args)
+		return source, pass, err
+	}
+}
+
+// readPassFd reads the passphrase from the descriptor and closes it, the read buffer is zeroed
+func readPassFd(fd int) (string, error) {
+	fdReadLock.Lock()
+	defer fdReadLock.Unlock()
+	if fdRead[fd] {
+		return "", fmt.Errorf("file descriptor %d was read already, it can only be read once", fd)
+	}
+	fdRead[fd] = true
+
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if f == nil {
+		return "", fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	defer wipeBytes(data)
+	if err != nil {
+		return "", err
+	}
+	return trimPass(data), nil
+}
+
+func wipeBytes(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
+
+// passCmdArgs the args of pass_cmd, a string is the command itself and never split,
+// so arguments with spaces need the list form
+func passCmdArgs(value interface{}) ([]string, error) {
+	var args []string
+	switch v := value.(type) {
+	case string:
+		args = []string{v}
+	case []interface{}:
+		for _, arg := range v {
+			args = append(args, fmt.Sprint(arg))
+		}
+	case []string:
+		args = v
+	}
+	if len(args) == 0 || args[0] == "" {
+		return nil, fmt.Errorf("pass_cmd must be a list of args, eg. [vault, read, -field=pass, secret/signer], got %v", value)
+	}
+	return args, nil
+}
+
+// runPassCmd runs the helper without a shell, its stdout is the passphrase
+func runPassCmd(args []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), passCmdTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	defer func() { wipeBytes(stdout.Bytes()) }()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return trimPass(stdout.Bytes()), nil
+}
+
+// trimPass drops the line break a file or a command ends with, other spaces belong to the passphrase
+func trimPass(data []byte) string {
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+}
@@ -0,0 +1,125 @@
+package account
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// passFd a descriptor reading pass, owned by the code under test
+func passFd(t *testing.T, pass string) int {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err = w.WriteString(pass); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	fd, err := syscall.Dup(int(r.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the number of a descriptor closed by an earlier test may come back
+	fdReadLock.Lock()
+	delete(fdRead, fd)
+	fdReadLock.Unlock()
+	return fd
+}
+
+func TestReadPassSource(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pass")
+	if err := os.WriteFile(file, []byte("file pass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SIGNER_PASS", "env pass\r\n")
+
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{"none", map[string]interface{}{}, "", false},
+		{"file", map[string]interface{}{passFileField: file}, "file pass", false},
+		{"missing file", map[string]interface{}{passFileField: file + ".missing"}, "", true},
+		{"env", map[string]interface{}{passEnvField: "TEST_SIGNER_PASS"}, "env pass", false},
+		{"unset env", map[string]interface{}{passEnvField: "TEST_SIGNER_PASS_UNSET"}, "", true},
+		{"fd", map[string]interface{}{passFdField: passFd(t, "fd pass\n")}, "fd pass", false},
+		{"invalid fd", map[string]interface{}{passFdField: "three"}, "", true},
+		{"cmd keeps quoted args", map[string]interface{}{passCmdField: []interface{}{"echo", "cmd  pass"}}, "cmd  pass", false},
+		{"bare cmd", map[string]interface{}{passCmdField: "true"}, "", false},
+		{"cmd string is not split", map[string]interface{}{passCmdField: "echo pass"}, "", true},
+		{"failing cmd", map[string]interface{}{passCmdField: []interface{}{"false"}}, "", true},
+		{"two sources", map[string]interface{}{passFileField: file, passEnvField: "TEST_SIGNER_PASS"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, pass, err := readPassSource(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if pass != tt.want {
+				t.Fatalf("pass = %q, want %q", pass, tt.want)
+			}
+		})
+	}
+}
+
+func TestPassFdServesOneAccount(t *testing.T) {
+	fd := passFd(t, "secret")
+	if pass, err := readPassFd(fd); err != nil || pass != "secret" {
+		t.Fatalf("first read = %q, %v", pass, err)
+	}
+	if _, err := readPassFd(fd); err == nil {
+		t.Fatal("a descriptor was read twice")
+	}
+}
+
+func TestPassCmdArgs(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    []string
+		wantErr bool
+	}{
+		{[]interface{}{"vault", "read", "-field=pass", "secret/signer path"}, []string{"vault", "read", "-field=pass", "secret/signer path"}, false},
+		{[]string{"pass", "show"}, []string{"pass", "show"}, false},
+		{"/usr/local/bin/signer pass", []string{"/usr/local/bin/signer pass"}, false},
+		{"", nil, true},
+		{[]interface{}{}, nil, true},
+		{42, nil, true},
+	}
+	for _, tt := range tests {
+		args, err := passCmdArgs(tt.value)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(args, tt.want) {
+			t.Errorf("passCmdArgs(%v) = %v, %v, want %v", tt.value, args, err, tt.want)
+		}
+	}
+}
+
+func TestWipePass(t *testing.T) {
+	params := map[string]interface{}{passField: "p", passFileField: "f", passEnvField: "e", passFdField: 3, passCmdField: "c", "path": "keystore"}
+	wipePass(params)
+	if !reflect.DeepEqual(params, map[string]interface{}{"path": "keystore"}) {
+		t.Fatalf("params after wipe = %v", params)
+	}
+}
+
+func TestPassFdKeyUnlock(t *testing.T) {
+	key := newKey("treasury", "treasury", KeyStoreTy, 0, map[string]interface{}{"key": "treasury.json", passFdField: 3})
+	if key.CanUnlock() {
+		t.Fatal("a pass_fd key can unlock from its source")
+	}
+	if _, err := key.Unlock(""); !errors.Is(err, ErrNoPassphrase) {
+		t.Fatalf("unlock from pass_fd error = %v", err)
+	}
+
+	key = newKey("treasury", "treasury", KeyStoreTy, 0, map[string]interface{}{"key": "treasury.json", passEnvField: "TEST_SIGNER_PASS"})
+	if !key.CanUnlock() {
+		t.Fatal("a pass_env key can't unlock from its source")
+	}
+}
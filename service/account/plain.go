@@ -10,13 +10,21 @@ import (
 
 type plainMnemonic struct {
 	mnemonic string
+	path     string // the derivation path with the {index} placeholder
+	seedPass string // the BIP-39 passphrase
 	indexMap map[int64]struct{}
 }
 
-func NewPlainMnemonic(key, indexRange string) (*plainMnemonic, error) {
+func NewPlainMnemonic(key, indexRange, path, seedPass string) (*plainMnemonic, error) {
 	if key == "" || indexRange == "" {
 		return nil, fmt.Errorf("mnemonic config error")
 	}
+	if err := ethutils.ValidateMnemonic(key); err != nil {
+		return nil, fmt.Errorf("%w: unknown word or wrong checksum", ErrInvalidMnemonic)
+	}
+	if path == "" {
+		path = ethutils.DefaultPath
+	}
 
 	indexMap := make(map[int64]struct{})
 	splitMap, err := strutil.SplitNum(indexRange)
@@ -28,24 +36,32 @@ func NewPlainMnemonic(key, indexRange string) (*plainMnemonic, error) {
 		__index, _ := strconv.ParseInt(_index, 10, 64)
 		indexMap[__index] = struct{}{}
 	}
-	return &plainMnemonic{mnemonic: key, indexMap: indexMap}, nil
+	return &plainMnemonic{mnemonic: key, path: path, seedPass: seedPass, indexMap: indexMap}, nil
 }
 
-func (p *plainMnemonic) Decrypt() []*types.Account {
+func (p *plainMnemonic) Decrypt() ([]*types.Account, error) {
+	deriver, err := ethutils.NewDeriver(p.mnemonic, p.seedPass, p.path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDerive, err)
+	}
 	var accounts []*types.Account
 	for k := range p.indexMap {
 		account := &types.Account{
 			Index: k,
 		}
 
-		key := ethutils.GetAccountFromMnemonic(p.mnemonic, int(k))
+		key, err := deriver.Derive(int(k))
+		if err != nil {
+			return nil, indexError(k, ErrDerive, err)
+		}
 		account.Address = key.Address
 		logger.Debugf("index: [%d], address: [%s] \n", k, account.Address.String())
 		account.PriKey = key.PrivateKey
+		account.Path, account.XPub = deriver.PathOf(int(k)), deriver.XPub()
 
 		accounts = append(accounts, account)
 	}
-	return accounts
+	return accounts, nil
 }
 
 func (p *plainMnemonic) Crypto() error {
@@ -56,13 +72,12 @@ type plainPrivateKey struct {
 	key string
 }
 
-func (p *plainPrivateKey) Decrypt() []*types.Account {
+func (p *plainPrivateKey) Decrypt() ([]*types.Account, error) {
 	var accounts []*types.Account
 
 	account := ethutils.GetAccountFromPStr(p.key)
 	if account == nil {
-		logger.Errorf("plain privateKey is invalidate")
-		return nil
+		return nil, fmt.Errorf("%w: not a 32 byte hex key", ErrInvalidKey)
 	}
 
 	logger.Infof("plain privateKey address: [%s]", account.Address)
@@ -71,7 +86,7 @@ func (p *plainPrivateKey) Decrypt() []*types.Account {
 		PriKey:  account.PrivateKey,
 	}
 	accounts = append(accounts, _account)
-	return accounts
+	return accounts, nil
 }
 
 func (p *plainPrivateKey) Crypto() error {
@@ -0,0 +1,53 @@
+package account
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Restriction the chains and the operation kinds an account may sign for, an empty list allows all
+type Restriction struct {
+	ChainIds   []int64  `json:"chain_ids,omitempty"`
+	Operations []string `json:"operations,omitempty"` // transaction, eip712 or message
+}
+
+// Allows nil when the account may sign the operation on the chain, a nil restriction allows everything
+func (r *Restriction) Allows(chainId int64, operation string) error {
+	if r == nil {
+		return nil
+	}
+	if len(r.ChainIds) != 0 && !containsChain(r.ChainIds, chainId) {
+		return fmt.Errorf("chain_id %d is not one of [ %s ]", chainId, joinChains(r.ChainIds))
+	}
+	if len(r.Operations) != 0 && !containsOperation(r.Operations, operation) {
+		return fmt.Errorf("%s is not one of [ %s ]", operation, strings.Join(r.Operations, ", "))
+	}
+	return nil
+}
+
+func containsChain(chainIds []int64, chainId int64) bool {
+	for _, id := range chainIds {
+		if id == chainId {
+			return true
+		}
+	}
+	return false
+}
+
+func containsOperation(operations []string, operation string) bool {
+	for _, op := range operations {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+func joinChains(chainIds []int64) string {
+	ids := make([]string, 0, len(chainIds))
+	for _, id := range chainIds {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(ids, ", ")
+}
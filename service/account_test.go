@@ -0,0 +1,40 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccountRestriction(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     map[string]interface{}
+		operations []string
+		wantErr    bool
+	}{
+		{name: "none", params: map[string]interface{}{}},
+		{name: "operations", params: map[string]interface{}{"operations": []interface{}{"message", "eip712"}},
+			operations: []string{OpMessage, OpEIP712}},
+		{name: "no endpoint signs it", params: map[string]interface{}{"operations": []interface{}{"user_operation"}}, wantErr: true},
+		{name: "unknown", params: map[string]interface{}{"operations": []interface{}{"transfer"}}, wantErr: true},
+		{name: "not a list", params: map[string]interface{}{"operations": "message"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restriction, err := accountRestriction(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var operations []string
+			if restriction != nil {
+				operations = restriction.Operations
+			}
+			if !reflect.DeepEqual(operations, tt.operations) {
+				t.Fatalf("operations = %v, want %v", operations, tt.operations)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"crypto/subtle"
+	"evm-signer/service/account"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"sort"
+	"strings"
+	"time"
+)
+
+const adminKey = "admin"
+
+type AdminConfig struct {
+	IP     string            `mapstructure:"ip"`     // optional admin ip whitelist, comma separated
+	Tokens map[string]string `mapstructure:"tokens"` // admin name: bearer token
+}
+
+func (s *Service) SetAdminConfig(adminConfig *AdminConfig) {
+	s.admin = adminConfig
+}
+
+// AdminAuth authenticates admin requests by their bearer token, the admin name is kept in the context
+func (s *Service) AdminAuth(ctx *gin.Context) {
+	if s.admin == nil || len(s.admin.Tokens) == 0 {
+		ReturnError(ctx, AuthError, "admin api is disabled, no admin tokens configured")
+		return
+	}
+
+	if s.admin.IP != "" {
+		if _, ok := GetIpWhiteList(strings.Split(s.admin.IP, ","))[ctx.ClientIP()]; !ok {
+			_msg := fmt.Sprintf("ip: [ %s ] illegal", ctx.ClientIP())
+			logger.Errorf("[Admin] %s", _msg)
+			s.notifyAuthFailed(ctx, "admin ip not whitelisted")
+			ReturnError(ctx, IllegalAccess, _msg)
+			return
+		}
+	}
+
+	token := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	for name, adminToken := range s.admin.Tokens {
+		if adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+			ctx.Set(adminKey, name)
+			ctx.Next()
+			return
+		}
+	}
+
+	logger.Errorf("[Admin] invalid token from ip: [ %s ]", ctx.ClientIP())
+	s.notifyAuthFailed(ctx, "invalid admin token")
+	ctx.AbortWithStatusJSON(401, ResponseMsg{
+		Code: AuthError,
+		Msg:  "invalid admin token",
+	})
+}
+
+type accountInfo struct {
+	Address string   `json:"address"`
+	Name    string   `json:"name"` // the account source
+	Index   int64    `json:"index"`
+	Ref     string   `json:"ref"` // source:index
+	Aliases []string `json:"aliases,omitempty"`
+	Source  string   `json:"source"`
+	Loaded  bool     `json:"loaded"`        // the private key was decrypted
+	Key     string   `json:"key,omitempty"` // the source of the key the account belongs to
+	Locked  bool     `json:"locked"`
+
+	Restriction *account.Restriction `json:"restriction,omitempty"` // the chains and operations it may sign
+}
+
+// ListAccounts the loaded accounts, keys are never exposed
+func (s *Service) ListAccounts(ctx *gin.Context) {
+	s.lock.RLock()
+	accounts := make([]*accountInfo, 0, len(s.accountsForAddr))
+	for address, account := range s.accountsForAddr {
+		info := &accountInfo{
+			Address: account.Address.Hex(),
+			Name:    account.Name,
+			Index:   account.Index,
+			Ref:     fmt.Sprintf("%s:%d", account.Name, account.Index),
+			Source:  account.Source,
+			Loaded:  s.hasKey(account),
+		}
+		if s.keyring != nil {
+			if state, ok := s.keyring.keyForRef[address]; ok {
+				info.Key, info.Locked = state.key.Source, state.locked()
+			}
+			for alias, ref := range s.keyring.aliases {
+				if ref == accountRef(account.Name, account.Index) {
+					info.Aliases = append(info.Aliases, alias)
+				}
+			}
+			sort.Strings(info.Aliases)
+			info.Restriction = s.keyring.restrictionOf(account)
+		}
+		accounts = append(accounts, info)
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Name != accounts[j].Name {
+			return accounts[i].Name < accounts[j].Name
+		}
+		if accounts[i].Index != accounts[j].Index {
+			return accounts[i].Index < accounts[j].Index
+		}
+		return accounts[i].Address < accounts[j].Address
+	})
+	ReturnSuccess(ctx, accounts)
+}
+
+// ListChains the configured chains
+func (s *Service) ListChains(ctx *gin.Context) {
+	s.lock.RLock()
+	chains := make([]*ChainConfig, 0, len(s.chains))
+	for _, chain := range s.chains {
+		chains = append(chains, chain)
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].ChainId < chains[j].ChainId
+	})
+	ReturnSuccess(ctx, chains)
+}
+
+// ListRules the active rules in match order, with the content hash of the rule set
+func (s *Service) ListRules(ctx *gin.Context) {
+	sources := make([]string, len(s.rules))
+	for i, rule := range s.rules {
+		sources[i] = rule.Source()
+	}
+	ReturnSuccess(ctx, gin.H{
+		"hash":      s.rules.Hash(),
+		"count":     s.rules.Length(),
+		"loaded_at": s.rulesLoadedAt,
+		"rules":     s.rules,
+		"sources":   sources,
+	})
+}
+
+// Status the process status of the signer
+func (s *Service) Status(ctx *gin.Context) {
+	pending := 0
+	if s.approvals != nil {
+		pending = len(s.approvals.list(ApprovalPending))
+	}
+	s.lock.RLock()
+	accounts, chains := len(s.accountsForAddr), len(s.chains)
+	keys, locked := s.lockedKeys()
+	s.lock.RUnlock()
+
+	uptime := time.Since(s.startedAt)
+	ReturnSuccess(ctx, gin.H{
+		"started_at":        s.startedAt,
+		"uptime":            uptime.Round(time.Second).String(),
+		"uptime_seconds":    int64(uptime.Round(time.Second).Seconds()),
+		"rules_loaded_at":   s.rulesLoadedAt,
+		"rules_hash":        s.rules.Hash(),
+		"accounts":          accounts,
+		"chains":            chains,
+		"keys":              keys,
+		"locked_keys":       locked,
+		"nonce_tracking":    s.nonces != nil,
+		"policy":            s.policy != nil,
+		"pending_approvals": pending,
+	})
+}
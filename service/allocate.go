@@ -0,0 +1,207 @@
+package service
+
+import (
+	"encoding/json"
+	"evm-signer/pkg/jsonfile"
+	"evm-signer/pkg/strutil"
+	"evm-signer/service/account"
+	"evm-signer/service/webhook"
+	sTypes "evm-signer/types"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const defaultAllocationFile = "data/allocations.json"
+
+type AllocationConfig struct {
+	File string `mapstructure:"file"` // the next index of every source, default data/allocations.json
+}
+
+// Allocator hands out the deposit addresses of the sources with allocate enabled. The next index of a source
+// is persisted before its address is handed out, so no index is handed out twice
+type Allocator struct {
+	lock   sync.Mutex
+	config *AllocationConfig
+	nexts  map[string]int64 // lower case source name: the next index to try
+}
+
+// allocatable a source which hands out deposit addresses
+type allocatable struct {
+	name       string
+	indexRange string
+	ranges     strutil.Ranges
+}
+
+func NewAllocator(config *AllocationConfig) (*Allocator, error) {
+	if config.File == "" {
+		config.File = defaultAllocationFile
+	}
+	a := &Allocator{
+		config: config,
+		nexts:  make(map[string]int64),
+	}
+	if err := jsonfile.Load(config.File, &a.nexts); err != nil {
+		return nil, fmt.Errorf("load allocation file %s error: %s", config.File, err)
+	}
+	return a, nil
+}
+
+// next the next index of a source
+func (a *Allocator) next(source string) int64 {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.nexts[strings.ToLower(source)]
+}
+
+// SetAllocator sets the allocator, the lazy mnemonics derive the accounts handed out already
+func (s *Service) SetAllocator(allocator *Allocator) {
+	s.allocator = allocator
+
+	type lazy struct {
+		wallet *account.HDWallet
+		state  *keyState
+	}
+	var wallets []lazy
+	s.lock.RLock()
+	if s.keyring != nil {
+		for _, wallet := range s.keyring.wallets {
+			wallets = append(wallets, lazy{wallet: wallet})
+		}
+		for _, state := range s.keyring.keys {
+			if state.wallet != nil {
+				wallets = append(wallets, lazy{wallet: state.wallet, state: state})
+			}
+		}
+	}
+	s.lock.RUnlock()
+	for _, _lazy := range wallets {
+		s.preload(_lazy.wallet, _lazy.state)
+	}
+}
+
+// allocate hands out the next index of the source which is neither handed out nor aliased
+func (s *Service) allocate(source string) (*sTypes.AllocatedAddress, ErrCode, error) {
+	var target *allocatable
+	if s.keyring != nil {
+		target = s.keyring.allocatable[strings.ToLower(source)]
+	}
+	if target == nil || s.allocator == nil {
+		return nil, ParamError, fmt.Errorf("[ %s ] is no account source with allocate enabled", source)
+	}
+
+	a := s.allocator
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	key := strings.ToLower(target.name)
+	index, ok := target.ranges.Next(a.nexts[key])
+	for ok && len(s.keyring.refAliases[accountRef(target.name, index)]) != 0 {
+		index, ok = target.ranges.Next(index + 1)
+	}
+	if !ok {
+		return nil, InvalidFormData, fmt.Errorf("every index of [ %s ] %s is allocated", target.name, target.indexRange)
+	}
+
+	// kept like the indices allocated before, so the deposit is found by its address
+	ref := accountRef(target.name, index)
+	_account, ok := s.derive(ref, true)
+	if !ok {
+		if s.isLocked(ref) {
+			return nil, AccountLocked, fmt.Errorf("[ %s ] is locked, unlock it with `signer unlock`", target.name)
+		}
+		return nil, InternalError, fmt.Errorf("[ %s ] has no account", ref)
+	}
+
+	prev := a.nexts[key]
+	a.nexts[key] = index + 1
+	if err := jsonfile.Save(a.config.File, a.nexts); err != nil {
+		a.nexts[key] = prev
+		return nil, InternalError, fmt.Errorf("save allocation file error: %s", err)
+	}
+	return &sTypes.AllocatedAddress{
+		Source:  target.name,
+		Index:   index,
+		Account: fmt.Sprintf("%s:%d", target.name, index),
+		Address: _account.Address.Hex(),
+	}, 0, nil
+}
+
+// AllocateAddress hands out the next unused deposit address of a source
+func (s *Service) AllocateAddress(ctx *gin.Context) {
+	if err := s.CheckIp(ctx); err != nil {
+		_msg := fmt.Sprintf("ip: [ %s ] illegal", ctx.ClientIP())
+		ReturnError(ctx, IllegalAccess, _msg)
+		return
+	}
+
+	if !s.limitClient(ctx) {
+		return
+	}
+
+	msgData, code, err := s.getMsgData(ctx)
+	if err != nil {
+		_msg := fmt.Sprintf("parser msgData for allocateAddress error: [ %s ]", err.Error())
+		logger.Errorf(_msg)
+		ReturnError(ctx, code, _msg)
+		return
+	}
+
+	msgInfo := sTypes.AllocateMsgInfo{}
+	if err = json.Unmarshal(msgData, &msgInfo); err != nil {
+		_msg := fmt.Sprintf("decode msgData for allocateAddress error: [ %s ]", err.Error())
+		logger.Errorf(_msg)
+		ReturnError(ctx, ParamError, _msg)
+		return
+	}
+
+	if msgInfo.Source == "" {
+		_msg := "source is null, plz check your source"
+		logger.Errorf(_msg)
+		ReturnError(ctx, InvalidFormData, _msg)
+		return
+	}
+
+	allocated, code, err := s.allocate(msgInfo.Source)
+	if err != nil {
+		_msg := fmt.Sprintf("[Allocate] %s", err)
+		logger.Errorf(_msg)
+		ReturnError(ctx, code, _msg)
+		return
+	}
+
+	logger.Infof("request ip: [ %s ], source: [ %s ], allocated index: [ %d ], address: [ %s ]",
+		ctx.ClientIP(), allocated.Source, allocated.Index, allocated.Address)
+	s.notifier.Emit(webhook.AddressAllocated, gin.H{
+		"source":  allocated.Source,
+		"index":   allocated.Index,
+		"address": allocated.Address,
+		"client":  ctx.ClientIP(),
+	})
+	ctx.AbortWithStatusJSON(200, allocated)
+}
+
+type allocationInfo struct {
+	Source string `json:"source"`
+	Index  string `json:"index"` // the index range of the source
+	Next   int64  `json:"next"`  // the next index to try
+}
+
+// ListAllocations the sources which hand out deposit addresses and their next index
+func (s *Service) ListAllocations(ctx *gin.Context) {
+	allocations := make([]*allocationInfo, 0)
+	if s.keyring != nil && s.allocator != nil {
+		for _, target := range s.keyring.allocatable {
+			allocations = append(allocations, &allocationInfo{
+				Source: target.name,
+				Index:  target.indexRange,
+				Next:   s.allocator.next(target.name),
+			})
+		}
+	}
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].Source < allocations[j].Source
+	})
+	ReturnSuccess(ctx, allocations)
+}
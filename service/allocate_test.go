@@ -0,0 +1,175 @@
+package service
+
+import (
+	"encoding/json"
+	"evm-signer/service/account"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// newTestAllocateService a service with a lazy deposits source handing out addresses, its allocations saved to file
+func newTestAllocateService(t *testing.T, file string) *Service {
+	t.Helper()
+	kr, err := account.NewKeyring(&account.Source{
+		Name:     "deposits",
+		Params:   map[string]interface{}{"type": "PlainMnemonic", "key": testMnemonic, "index": "0-999999", "lazy": true},
+		Aliases:  map[string]int64{"cold": 3},
+		Allocate: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{}
+	if err = s.SetKeyring(kr, &LockConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	allocator, err := NewAllocator(&AllocationConfig{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetAllocator(allocator)
+	return s
+}
+
+func savedNext(t *testing.T, file string) int64 {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nexts := make(map[string]int64)
+	if err = json.Unmarshal(data, &nexts); err != nil {
+		t.Fatal(err)
+	}
+	return nexts["deposits"]
+}
+
+func TestAllocatePersistsAcrossRestart(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "allocations.json")
+	s := newTestAllocateService(t, file)
+
+	var addresses []string
+	for _, want := range []int64{0, 1, 2, 4} { // 3 has an alias
+		allocated, _, err := s.allocate("deposits")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allocated.Index != want {
+			t.Fatalf("allocated index = %d, want %d", allocated.Index, want)
+		}
+		if next := savedNext(t, file); next != want+1 {
+			t.Fatalf("saved next = %d after index %d", next, want)
+		}
+		addresses = append(addresses, allocated.Address)
+	}
+
+	// a restart goes on with the saved index and finds the deposits by their address
+	s = newTestAllocateService(t, file)
+	allocated, _, err := s.allocate("deposits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocated.Index != 5 {
+		t.Fatalf("allocated index after a restart = %d, want 5", allocated.Index)
+	}
+	for _, address := range addresses {
+		ai, err := s.GetAccount(address, 1, OpTransaction)
+		if err != nil {
+			t.Fatalf("GetAccount of allocated [ %s ] error: %s", address, err)
+		}
+		// the derived accounts keep no private key, signing derives it again
+		if ai.PriKey != nil {
+			t.Fatalf("[ %s ] keeps its private key", address)
+		}
+		key := s.signingKey(ai)
+		if key == nil || crypto.PubkeyToAddress(key.PublicKey) != ai.Address {
+			t.Fatalf("signing key of [ %s ] does not match", address)
+		}
+		account.ZeroKey(key)
+	}
+}
+
+func TestAllocateConcurrent(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "allocations.json")
+	s := newTestAllocateService(t, file)
+
+	const clients = 16
+	indices := make(chan int64, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			allocated, _, err := s.allocate("deposits")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			indices <- allocated.Index
+		}()
+	}
+	wg.Wait()
+	close(indices)
+
+	seen := make(map[int64]bool)
+	for index := range indices {
+		if seen[index] || index == 3 {
+			t.Fatalf("index %d handed out twice or despite its alias", index)
+		}
+		seen[index] = true
+	}
+	if len(seen) != clients {
+		t.Fatalf("allocated %d indices, want %d", len(seen), clients)
+	}
+	if next := savedNext(t, file); next != clients+1 {
+		t.Fatalf("saved next = %d, want %d", next, clients+1)
+	}
+}
+
+func TestLookupUnallocatedNotKept(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "allocations.json")
+	s := newTestAllocateService(t, file)
+	if len(s.accountsForAddr) != 0 {
+		t.Fatalf("%d accounts loaded before any allocation", len(s.accountsForAddr))
+	}
+
+	// an index nobody allocated resolves and signs, but is derived again every time
+	for _, ref := range []string{"deposits:123456", "deposits:999999"} {
+		ai, err := s.GetAccount(ref, 1, OpTransaction)
+		if err != nil {
+			t.Fatalf("GetAccount [ %s ] error: %s", ref, err)
+		}
+		key := s.signingKey(ai)
+		if key == nil || crypto.PubkeyToAddress(key.PublicKey) != ai.Address {
+			t.Fatalf("signing key of [ %s ] does not match", ref)
+		}
+		account.ZeroKey(key)
+	}
+	if len(s.accountsForAddr) != 0 || len(s.accountForRef) != 0 {
+		t.Fatalf("lookups kept %d accounts", len(s.accountsForAddr))
+	}
+
+	// an alias and an allocated index are kept and found by their address
+	cold, ok := s.LookupAccount("cold")
+	if !ok {
+		t.Fatal("no account [ cold ]")
+	}
+	allocated, _, err := s.allocate("deposits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range []string{cold.Address.Hex(), allocated.Address} {
+		if _, err = s.GetAccount(address, 1, OpTransaction); err != nil {
+			t.Fatalf("GetAccount of kept [ %s ] error: %s", address, err)
+		}
+	}
+	if len(s.accountsForAddr) != 2 {
+		t.Fatalf("%d accounts kept, want 2", len(s.accountsForAddr))
+	}
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"evm-signer/pkg/jsonfile"
+	"evm-signer/service/rules"
+	"evm-signer/service/webhook"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultApprovalFile = "data/approvals.json"
+	defaultApprovalTTL  = 24 * time.Hour
+)
+
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved" // quorum reached, the request is being signed
+	ApprovalSigned   ApprovalStatus = "signed"
+	ApprovalFailed   ApprovalStatus = "failed" // quorum reached, but signing was refused
+	ApprovalRejected ApprovalStatus = "rejected"
+	ApprovalExpired  ApprovalStatus = "expired"
+)
+
+type ApprovalConfig struct {
+	File      string `mapstructure:"file"`
+	TTL       string `mapstructure:"ttl"`       // how long a request waits for approvals, default 24h
+	Approvers string `mapstructure:"approvers"` // admin names allowed to approve, comma separated, default all admins
+}
+
+// Approval a signing request parked until its quorum of approvers is reached
+type Approval struct {
+	ID         string          `json:"id"`
+	Path       string          `json:"path"`
+	ChainId    int64           `json:"chain_id"`
+	Account    string          `json:"account"`
+	Client     string          `json:"client"`
+	Rule       string          `json:"rule"`
+	RuleSource string          `json:"rule_source,omitempty"` // the rule file and line
+	Request    string          `json:"request"`
+	Quorum     int             `json:"quorum"`
+	Approvers  []string        `json:"approvers"`
+	Rejecter   string          `json:"rejecter,omitempty"`
+	Status     ApprovalStatus  `json:"status"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ExpiresAt  time.Time       `json:"expires_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// ApprovalQueue persists the parked signing requests
+type ApprovalQueue struct {
+	lock      sync.Mutex
+	file      string
+	ttl       time.Duration
+	approvers map[string]struct{}
+	items     map[string]*Approval
+	notifier  *webhook.Dispatcher
+}
+
+func NewApprovalQueue(config *ApprovalConfig) (*ApprovalQueue, error) {
+	q := &ApprovalQueue{
+		file:      config.File,
+		ttl:       defaultApprovalTTL,
+		approvers: make(map[string]struct{}),
+		items:     make(map[string]*Approval),
+	}
+	for _, approver := range strings.Split(config.Approvers, ",") {
+		if approver = strings.TrimSpace(approver); approver != "" {
+			q.approvers[approver] = struct{}{}
+		}
+	}
+	if q.file == "" {
+		q.file = defaultApprovalFile
+	}
+	if config.TTL != "" {
+		ttl, err := time.ParseDuration(config.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid approval ttl %s", config.TTL)
+		}
+		q.ttl = ttl
+	}
+	if err := jsonfile.Load(q.file, &q.items); err != nil {
+		return nil, fmt.Errorf("load approval file %s error: %s", q.file, err)
+	}
+	// the signer stopped while signing these, whether they were signed is unknown
+	for _, item := range q.items {
+		if item.Status == ApprovalApproved {
+			item.Status = ApprovalFailed
+		}
+	}
+	return q, nil
+}
+
+func (s *Service) SetApprovalQueue(approvals *ApprovalQueue) {
+	s.approvals = approvals
+	s.approvals.notifier = s.notifier
+}
+
+// expire marks timed out requests and drops finished requests older than the ttl, lock must be held
+func (q *ApprovalQueue) expire(now time.Time) {
+	for id, item := range q.items {
+		if item.Status == ApprovalPending && now.After(item.ExpiresAt) {
+			item.Status = ApprovalExpired
+			item.UpdatedAt = now
+			q.notifier.Emit(webhook.ApprovalExpired, approvalEvent(item))
+		} else if item.Status != ApprovalPending && now.Sub(item.UpdatedAt) > q.ttl {
+			delete(q.items, id)
+		}
+	}
+}
+
+func (q *ApprovalQueue) add(item *Approval) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.expire(item.CreatedAt)
+	q.items[item.ID] = item
+	return jsonfile.Save(q.file, q.items)
+}
+
+func (q *ApprovalQueue) get(id string) (*Approval, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.expire(time.Now())
+	item, ok := q.items[id]
+	if !ok {
+		return nil, false
+	}
+	_item := *item
+	return &_item, true
+}
+
+func (q *ApprovalQueue) list(status ApprovalStatus) []*Approval {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.expire(time.Now())
+	items := make([]*Approval, 0, len(q.items))
+	for _, item := range q.items {
+		if status == "" || item.Status == status {
+			_item := *item
+			items = append(items, &_item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+	return items
+}
+
+// approvedKey marks a request replayed after its approval quorum was reached
+type approvedKey struct{}
+
+func isApproved(ctx *gin.Context) bool {
+	return ctx.Request.Context().Value(approvedKey{}) != nil
+}
+
+// park queues the request of a rule which requires approval and responds with the request id
+func (s *Service) park(ctx *gin.Context, msgData []byte, chainId int64, account string, matchRule *rules.Rule) {
+	if s.approvals == nil {
+		_msg := fmt.Sprintf("rule [ %s ] requires approval, but the approval queue is disabled", matchRule.Name)
+		logger.Errorf(_msg)
+		ReturnError(ctx, InternalError, _msg)
+		return
+	}
+
+	now := time.Now()
+	quorum := matchRule.Approvals
+	if quorum <= 0 {
+		quorum = 1
+	}
+	item := &Approval{
+		ID:         uuid.New().String(),
+		Path:       ctx.FullPath(),
+		ChainId:    chainId,
+		Account:    account,
+		Client:     ctx.ClientIP(),
+		Rule:       matchRule.Name,
+		RuleSource: matchRule.Source(),
+		Request:    string(msgData),
+		Quorum:     quorum,
+		Approvers:  []string{},
+		Status:     ApprovalPending,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(s.approvals.ttl),
+		UpdatedAt:  now,
+	}
+	if err := s.approvals.add(item); err != nil {
+		_msg := fmt.Sprintf("save approval request error: [ %s ]", err.Error())
+		logger.Errorf(_msg)
+		ReturnError(ctx, InternalError, _msg)
+		return
+	}
+
+	logger.Infof("[Approval] request ip: [ %s ], chain_id: [ %d ], account: [ %s ], rule: [ %s ] parked as [ %s ]",
+		item.Client, chainId, account, matchRule.Name, item.ID)
+	s.notifier.Emit(webhook.ApprovalCreated, approvalEvent(item))
+	setCode(ctx, PendingApproval)
+	ctx.AbortWithStatusJSON(http.StatusAccepted, ResponseMsg{
+		Code: PendingApproval,
+		Msg:  fmt.Sprintf("rule [ %s ] requires %d approvals", matchRule.Name, quorum),
+		Data: gin.H{"request_id": item.ID, "expires_at": item.ExpiresAt},
+	})
+}
+
+// replay runs an approved request through the router again, the rules, fee ceilings, policy and nonce
+// checks apply as they are at signing time. The rate limits counted the request when it was parked and
+// are not taken again
+func (s *Service) replay(item *Approval) (int, []byte) {
+	form := url.Values{"data": {item.Request}}
+	req := httptest.NewRequest(http.MethodPost, item.Path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = net.JoinHostPort(item.Client, "0")
+	req = req.WithContext(context.WithValue(req.Context(), approvedKey{}, item.ID))
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w.Code, w.Body.Bytes()
+}
+
+// decide records the approval or rejection of an admin, the request is signed once its quorum is reached.
+// The queue is not locked while the request is signed
+func (s *Service) decide(id, admin string, approve bool) (*Approval, ErrCode, error) {
+	item, code, err := s.approvals.record(id, admin, approve)
+	if err != nil || item.Status != ApprovalApproved {
+		return item, code, err
+	}
+
+	status, body := s.replay(item)
+	return s.approvals.finish(id, status, body)
+}
+
+// record adds the decision of an admin to a pending request, it marks the request approved once its quorum is reached
+func (q *ApprovalQueue) record(id, admin string, approve bool) (*Approval, ErrCode, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	now := time.Now()
+	q.expire(now)
+	item, ok := q.items[id]
+	if !ok {
+		return nil, ParamError, fmt.Errorf("approval request [ %s ] not found", id)
+	}
+	if item.Status != ApprovalPending {
+		return nil, ApprovalClosed, fmt.Errorf("approval request [ %s ] is %s", id, item.Status)
+	}
+	if len(q.approvers) > 0 {
+		if _, ok = q.approvers[admin]; !ok {
+			return nil, AuthError, fmt.Errorf("[ %s ] is not an approver", admin)
+		}
+	}
+
+	item.UpdatedAt = now
+	if !approve {
+		item.Status = ApprovalRejected
+		item.Rejecter = admin
+		q.notifier.Emit(webhook.ApprovalRejected, approvalEvent(item))
+	} else {
+		for _, approver := range item.Approvers {
+			if approver == admin {
+				return nil, ParamError, fmt.Errorf("[ %s ] already approved [ %s ]", admin, id)
+			}
+		}
+		item.Approvers = append(item.Approvers, admin)
+		q.notifier.Emit(webhook.ApprovalApproved, approvalEvent(item))
+		if len(item.Approvers) >= item.Quorum {
+			item.Status = ApprovalApproved
+		}
+	}
+
+	if err := jsonfile.Save(q.file, q.items); err != nil {
+		return nil, InternalError, fmt.Errorf("save approval file error: %s", err)
+	}
+	_item := *item
+	return &_item, 0, nil
+}
+
+// finish records the response of an approved request
+func (q *ApprovalQueue) finish(id string, status int, body []byte) (*Approval, ErrCode, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	item, ok := q.items[id]
+	if !ok {
+		return nil, InternalError, fmt.Errorf("approval request [ %s ] was dropped while signing", id)
+	}
+	item.Result = body
+	item.Status = ApprovalSigned
+	eventType := webhook.ApprovalSigned
+	if status != http.StatusOK {
+		item.Status = ApprovalFailed
+		eventType = webhook.ApprovalFailed
+	}
+	item.UpdatedAt = time.Now()
+	q.notifier.Emit(eventType, approvalEvent(item))
+
+	if err := jsonfile.Save(q.file, q.items); err != nil {
+		return nil, InternalError, fmt.Errorf("save approval file error: %s", err)
+	}
+	_item := *item
+	return &_item, 0, nil
+}
+
+// ListApprovals lists the approval requests, optionally filtered by ?status=
+func (s *Service) ListApprovals(ctx *gin.Context) {
+	if s.approvals == nil {
+		ReturnError(ctx, InternalError, "approval queue is disabled")
+		return
+	}
+	ReturnSuccess(ctx, s.approvals.list(ApprovalStatus(ctx.Query("status"))))
+}
+
+func (s *Service) Approve(ctx *gin.Context) {
+	s.decideHandler(ctx, true)
+}
+
+func (s *Service) Reject(ctx *gin.Context) {
+	s.decideHandler(ctx, false)
+}
+
+func (s *Service) decideHandler(ctx *gin.Context, approve bool) {
+	if s.approvals == nil {
+		ReturnError(ctx, InternalError, "approval queue is disabled")
+		return
+	}
+
+	admin := ctx.GetString(adminKey)
+	item, code, err := s.decide(ctx.Param("id"), admin, approve)
+	if err != nil {
+		_msg := fmt.Sprintf("[Approval] %s", err.Error())
+		logger.Errorf(_msg)
+		ReturnError(ctx, code, _msg)
+		return
+	}
+
+	logger.Infof("[Approval] admin: [ %s ], request: [ %s ], approve: [ %t ], status: [ %s ], approvals: [ %d/%d ]",
+		admin, item.ID, approve, item.Status, len(item.Approvers), item.Quorum)
+	ReturnSuccess(ctx, item)
+}
+
+// GetApprovalResult lets the client fetch the signature of an approved request
+func (s *Service) GetApprovalResult(ctx *gin.Context) {
+	if err := s.CheckIp(ctx); err != nil {
+		_msg := fmt.Sprintf("ip: [ %s ] illegal", ctx.ClientIP())
+		ReturnError(ctx, IllegalAccess, _msg)
+		return
+	}
+	if s.approvals == nil {
+		ReturnError(ctx, InternalError, "approval queue is disabled")
+		return
+	}
+
+	item, ok := s.approvals.get(ctx.Param("id"))
+	if !ok || item.Client != ctx.ClientIP() {
+		ReturnError(ctx, ParamError, fmt.Sprintf("approval request [ %s ] not found", ctx.Param("id")))
+		return
+	}
+
+	switch item.Status {
+	case ApprovalPending, ApprovalApproved:
+		setCode(ctx, PendingApproval)
+		ctx.AbortWithStatusJSON(http.StatusAccepted, ResponseMsg{
+			Code: PendingApproval,
+			Msg:  fmt.Sprintf("%d of %d approvals", len(item.Approvers), item.Quorum),
+			Data: gin.H{"request_id": item.ID, "expires_at": item.ExpiresAt},
+		})
+	case ApprovalSigned:
+		ctx.Data(http.StatusOK, "application/json; charset=utf-8", item.Result)
+		ctx.Abort()
+	case ApprovalFailed:
+		ctx.Data(http.StatusBadRequest, "application/json; charset=utf-8", item.Result)
+		ctx.Abort()
+	default:
+		ReturnError(ctx, ApprovalClosed, fmt.Sprintf("approval request [ %s ] is %s", item.ID, item.Status))
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestApprovalService answers replayed requests with status, the handler lists the queue
+// while it runs so a queue locked during the replay deadlocks the test
+func newTestApprovalService(t *testing.T, status int) (*Service, *int) {
+	queue, err := NewApprovalQueue(&ApprovalConfig{File: filepath.Join(t.TempDir(), "approvals.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replays := 0
+	s := &Service{}
+	s.SetApprovalQueue(queue)
+	s.router = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replays++
+		queue.list("")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"code":0}`))
+	})
+	return s, &replays
+}
+
+func addTestApproval(t *testing.T, s *Service, quorum int, expiresAt time.Time) string {
+	now := time.Now()
+	item := &Approval{
+		ID:        "req-1",
+		Path:      "/v1/sign/transaction",
+		Client:    "127.0.0.1",
+		Rule:      "large_transfer",
+		Request:   "{}",
+		Quorum:    quorum,
+		Approvers: []string{},
+		Status:    ApprovalPending,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+		UpdatedAt: now,
+	}
+	if err := s.approvals.add(item); err != nil {
+		t.Fatal(err)
+	}
+	return item.ID
+}
+
+func TestApprovalQuorum(t *testing.T) {
+	s, replays := newTestApprovalService(t, http.StatusOK)
+	id := addTestApproval(t, s, 2, time.Now().Add(time.Hour))
+
+	item, _, err := s.decide(id, "alice", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Status != ApprovalPending || *replays != 0 {
+		t.Fatalf("after one approval status = %s, replays = %d", item.Status, *replays)
+	}
+
+	if _, code, err := s.decide(id, "alice", true); err == nil || code != ParamError {
+		t.Fatalf("duplicate approver: code = %d, error = %v", code, err)
+	}
+
+	item, _, err = s.decide(id, "bob", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Status != ApprovalSigned || *replays != 1 || string(item.Result) != `{"code":0}` {
+		t.Fatalf("after quorum status = %s, replays = %d, result = %s", item.Status, *replays, item.Result)
+	}
+
+	if _, code, err := s.decide(id, "carol", true); err == nil || code != ApprovalClosed {
+		t.Fatalf("approving a signed request: code = %d, error = %v", code, err)
+	}
+}
+
+func TestApprovalRejectedReplay(t *testing.T) {
+	s, replays := newTestApprovalService(t, http.StatusBadRequest)
+	id := addTestApproval(t, s, 1, time.Now().Add(time.Hour))
+
+	item, _, err := s.decide(id, "alice", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Status != ApprovalFailed || *replays != 1 {
+		t.Fatalf("status = %s, replays = %d", item.Status, *replays)
+	}
+}
+
+func TestApprovalExpired(t *testing.T) {
+	s, replays := newTestApprovalService(t, http.StatusOK)
+	id := addTestApproval(t, s, 1, time.Now().Add(-time.Second))
+
+	if _, code, err := s.decide(id, "alice", true); err == nil || code != ApprovalClosed {
+		t.Fatalf("approving an expired request: code = %d, error = %v", code, err)
+	}
+	item, ok := s.approvals.get(id)
+	if !ok || item.Status != ApprovalExpired || *replays != 0 {
+		t.Fatalf("status = %v, replays = %d", item, *replays)
+	}
+}
+
+func TestApprovalInterruptedOnRestart(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "approvals.json")
+	queue, err := NewApprovalQueue(&ApprovalConfig{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err = queue.add(&Approval{ID: "req-1", Status: ApprovalApproved, CreatedAt: now, ExpiresAt: now.Add(time.Hour), UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewApprovalQueue(&ApprovalConfig{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item, ok := reloaded.get("req-1"); !ok || item.Status != ApprovalFailed {
+		t.Fatalf("interrupted request = %v", item)
+	}
+}
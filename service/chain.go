@@ -1,9 +1,66 @@
 package service
 
+import (
+	"evm-signer/service/rules"
+	sTypes "evm-signer/types"
+	"fmt"
+)
+
 type ChainConfig struct {
-	Name      string
-	ChainType string `mapstructure:"chain_type"`
-	ChainId   uint64 `mapstructure:"chain_id"`
+	Name      string `json:"name"`
+	ChainType string `json:"chain_type" mapstructure:"chain_type"`
+	ChainId   uint64 `json:"chain_id" mapstructure:"chain_id"`
+
+	// default fee ceilings (decimal, in wei) applied to every transaction on the chain,
+	// unless the matched rule sets its own upper bound on the same field
+	MaxGas               string `json:"max_gas,omitempty" mapstructure:"max_gas"`
+	MaxGasPrice          string `json:"max_gas_price,omitempty" mapstructure:"max_gas_price"`
+	MaxFeePerGas         string `json:"max_fee_per_gas,omitempty" mapstructure:"max_fee_per_gas"`
+	MaxPriorityFeePerGas string `json:"max_priority_fee_per_gas,omitempty" mapstructure:"max_priority_fee_per_gas"`
+	MaxFee               string `json:"max_fee,omitempty" mapstructure:"max_fee"`
+
+	ceilings rules.Conditions
+}
+
+// initCeilings converts the fee ceilings to `<=` conditions
+func (c *ChainConfig) initCeilings() error {
+	c.ceilings = nil
+	ceilings := []struct {
+		field rules.Field
+		value string
+	}{
+		{rules.GasField, c.MaxGas},
+		{rules.GasPriceField, c.MaxGasPrice},
+		{rules.MaxFeePerGasField, c.MaxFeePerGas},
+		{rules.MaxPriorityFeePerGasField, c.MaxPriorityFeePerGas},
+		{rules.FeeField, c.MaxFee},
+	}
+	for _, ceiling := range ceilings {
+		if ceiling.value == "" {
+			continue
+		}
+		if _, ok := rules.ParseTxNumber(ceiling.value); !ok || has0xPrefix(ceiling.value) {
+			return fmt.Errorf("%s ceiling must be a decimal number, got %s", ceiling.field, ceiling.value)
+		}
+		condition := &rules.Condition{Field: ceiling.field, Symbol: rules.LessAndEqualSymbol, Value: ceiling.value}
+		condition.Init()
+		c.ceilings = append(c.ceilings, condition)
+	}
+	return nil
+}
+
+// CheckCeilings checks the transaction against the chain fee ceilings
+// which the matched rule doesn't bound itself
+func (c *ChainConfig) CheckCeilings(matchRule *rules.Rule, tx *sTypes.Transaction) error {
+	for _, ceiling := range c.ceilings {
+		if matchRule != nil && matchRule.BoundsField(ceiling.Field) {
+			continue
+		}
+		if !ceiling.IsMatch(tx) {
+			return fmt.Errorf("%s exceeds the [ %s ] chain ceiling %s", ceiling.Field, c.Name, ceiling.Value)
+		}
+	}
+	return nil
 }
 
 func (s *Service) SetChainMap(am map[uint64]*ChainConfig) {
@@ -11,8 +68,8 @@ func (s *Service) SetChainMap(am map[uint64]*ChainConfig) {
 }
 
 func (s *Service) GetChainConfig(chainID uint64) *ChainConfig {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 
 	chain, ok := s.chains[chainID]
 	if !ok {
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"evm-signer/service/rules"
+	sTypes "evm-signer/types"
+	"testing"
+)
+
+func testRule(conditions ...*rules.Condition) *rules.Rule {
+	_conditions := rules.Conditions(conditions)
+	_conditions.Init()
+	return &rules.Rule{Name: "test", Conditions: &_conditions}
+}
+
+func TestCheckCeilings(t *testing.T) {
+	chain := &ChainConfig{Name: "ethereum", MaxGasPrice: "100"}
+	if err := chain.initCeilings(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		rule     *rules.Rule
+		gasPrice string
+		wantErr  bool
+	}{
+		{"no rule below", nil, "100", false},
+		{"no rule above", nil, "101", true},
+		{"negative", nil, "-1", true},
+		{"negative hex", nil, "0x-1", true},
+		{"lower bound keeps the ceiling", testRule(&rules.Condition{Field: rules.GasPriceField, Symbol: rules.GrateAndEqualSymbol, Value: "1"}), "101", true},
+		{"upper bound replaces the ceiling", testRule(&rules.Condition{Field: rules.GasPriceField, Symbol: rules.LessAndEqualSymbol, Value: "500"}), "101", false},
+		{"equal replaces the ceiling", testRule(&rules.Condition{Field: rules.GasPriceField, Symbol: rules.EqualSymbol, Value: "200"}), "200", false},
+		{"in replaces the ceiling", testRule(&rules.Condition{Field: rules.GasPriceField, Symbol: rules.InSymbol, Value: "150,200"}), "150", false},
+		{"other field keeps the ceiling", testRule(&rules.Condition{Field: rules.GasField, Symbol: rules.LessAndEqualSymbol, Value: "21000"}), "101", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &sTypes.Transaction{Type: "0", GasPrice: tt.gasPrice}
+			err := chain.CheckCeilings(tt.rule, tx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckCeilings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInitCeilingsRejectsHex(t *testing.T) {
+	chain := &ChainConfig{Name: "ethereum", MaxFee: "0x10"}
+	if err := chain.initCeilings(); err == nil {
+		t.Fatal("a hex ceiling should be rejected")
+	}
+}
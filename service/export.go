@@ -0,0 +1,220 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"evm-signer/pkg/ethutils"
+	"evm-signer/service/account"
+	sTypes "evm-signer/types"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// mnemonicSource a PlainMnemonic or EncryptedMnemonic source, watch-only wallets derive its addresses from its xpub
+type mnemonicSource struct {
+	name       string
+	ty         string
+	path       string
+	indexRange string
+	lazy       bool
+}
+
+func newMnemonicSource(source *account.Source) *mnemonicSource {
+	ty, _ := source.Params["type"].(string)
+	if ty != string(account.PlainMnemonicTy) && ty != string(account.EncryptedMnemonicTy) {
+		return nil
+	}
+	path, _ := source.Params["path"].(string)
+	if path == "" {
+		path = ethutils.DefaultPath
+	}
+	indexRange, _ := source.Params["index"].(string)
+	lazy, _ := source.Params["lazy"].(bool)
+	return &mnemonicSource{name: source.Name, ty: ty, path: path, indexRange: indexRange, lazy: lazy}
+}
+
+// pagination the page, from 1, and the page size of the query
+func pagination(ctx *gin.Context) (int, int, error) {
+	page, pageSize := 1, defaultPageSize
+	var err error
+	if value := ctx.Query("page"); value != "" {
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("page must be a number >= 1")
+		}
+	}
+	if value := ctx.Query("page_size"); value != "" {
+		if pageSize, err = strconv.Atoi(value); err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return 0, 0, fmt.Errorf("page_size must be a number from 1 to %d", maxPageSize)
+		}
+	}
+	return page, pageSize, nil
+}
+
+// ListAddresses a page of the loaded addresses with their public keys, ordered by source and index. A lazy
+// mnemonic lists the accounts derived so far, a locked key its addresses without public keys
+func (s *Service) ListAddresses(ctx *gin.Context) {
+	if err := s.CheckIp(ctx); err != nil {
+		_msg := fmt.Sprintf("ip: [ %s ] illegal", ctx.ClientIP())
+		ReturnError(ctx, IllegalAccess, _msg)
+		return
+	}
+
+	if !s.limitClient(ctx) {
+		return
+	}
+
+	page, pageSize, err := pagination(ctx)
+	if err != nil {
+		_msg := fmt.Sprintf("list addresses error: [ %s ]", err)
+		logger.Errorf(_msg)
+		ReturnError(ctx, ParamError, _msg)
+		return
+	}
+	source := strings.ToLower(ctx.Query("source"))
+
+	s.lock.RLock()
+	ring := s.keyring
+	if ring == nil {
+		s.lock.RUnlock()
+		ReturnSuccess(ctx, &sTypes.AddressPage{Page: page, PageSize: pageSize, Addresses: []*sTypes.AddressInfo{}})
+		return
+	}
+	if _, ok := ring.order[source]; source != "" && !ok {
+		s.lock.RUnlock()
+		_msg := fmt.Sprintf("list addresses error: [ %s ] is no account source", ctx.Query("source"))
+		logger.Errorf(_msg)
+		ReturnError(ctx, ParamError, _msg)
+		return
+	}
+	accounts := make([]*sTypes.Account, 0, len(s.accountsForAddr))
+	locked := make(map[*sTypes.Account]bool)
+	publicKeys := make(map[*sTypes.Account]ecdsa.PublicKey) // read under the lock, a lock of the key drops it
+	for address, _account := range s.accountsForAddr {
+		if source != "" && strings.ToLower(_account.Name) != source {
+			continue
+		}
+		accounts = append(accounts, _account)
+		if _account.PriKey != nil {
+			publicKeys[_account] = _account.PriKey.PublicKey
+		} else if _account.PubKey != nil {
+			publicKeys[_account] = *_account.PubKey
+		}
+		if state, ok := ring.keyForRef[address]; ok && state.locked() {
+			locked[_account] = true
+		}
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(accounts, func(i, j int) bool {
+		a, b := accounts[i], accounts[j]
+		if a.Name != b.Name {
+			return ring.order[strings.ToLower(a.Name)] < ring.order[strings.ToLower(b.Name)]
+		}
+		if a.Index != b.Index {
+			return a.Index < b.Index
+		}
+		return a.Address.Hex() < b.Address.Hex()
+	})
+
+	start, end := (page-1)*pageSize, page*pageSize
+	if start > len(accounts) {
+		start = len(accounts)
+	}
+	if end > len(accounts) {
+		end = len(accounts)
+	}
+	addresses := make([]*sTypes.AddressInfo, 0, end-start)
+	for _, _account := range accounts[start:end] {
+		ref := accountRef(_account.Name, _account.Index)
+		info := &sTypes.AddressInfo{
+			Address: _account.Address.Hex(),
+			Source:  _account.Name,
+			Index:   _account.Index,
+			Account: fmt.Sprintf("%s:%d", _account.Name, _account.Index),
+			Aliases: append([]string{}, ring.refAliases[ref]...),
+			Type:    _account.Source,
+			Path:    _account.Path,
+			XPub:    _account.XPub,
+			Locked:  locked[_account],
+		}
+		sort.Strings(info.Aliases)
+		// only the public half of the key leaves the signer
+		if publicKey, ok := publicKeys[_account]; ok {
+			info.PublicKey = hexutil.Encode(crypto.CompressPubkey(&publicKey))
+			info.PublicKeyUncompressed = hexutil.Encode(crypto.FromECDSAPub(&publicKey))
+		}
+		addresses = append(addresses, info)
+	}
+
+	ReturnSuccess(ctx, &sTypes.AddressPage{
+		Total:     len(accounts),
+		Page:      page,
+		PageSize:  pageSize,
+		Addresses: addresses,
+	})
+}
+
+// ListXPubs the extended public key above the {index} element of every mnemonic source with its path, eg. the
+// external chain m/44'/60'/0'/0 for the default path, not the BIP-44 account key. The xpub of a locked
+// EncryptedMnemonic is known once it is unlocked and a path with a hardened {index} has none
+func (s *Service) ListXPubs(ctx *gin.Context) {
+	if err := s.CheckIp(ctx); err != nil {
+		_msg := fmt.Sprintf("ip: [ %s ] illegal", ctx.ClientIP())
+		ReturnError(ctx, IllegalAccess, _msg)
+		return
+	}
+
+	if !s.limitClient(ctx) {
+		return
+	}
+
+	xpubs := make([]*sTypes.XPubInfo, 0)
+	s.lock.RLock()
+	if ring := s.keyring; ring != nil {
+		known := make(map[string]string)
+		for _, _account := range s.accountsForAddr {
+			if _account.XPub != "" {
+				known[strings.ToLower(_account.Name)] = _account.XPub
+			}
+		}
+		for _, wallet := range ring.wallets {
+			known[strings.ToLower(wallet.Name)] = wallet.XPub()
+		}
+		for _, source := range ring.mnemonics {
+			info := &sTypes.XPubInfo{
+				Source:   source.name,
+				Type:     source.ty,
+				Path:     source.path,
+				Index:    source.indexRange,
+				Lazy:     source.lazy,
+				XPub:     known[strings.ToLower(source.name)],
+				XPubPath: ethutils.XPubPath(source.path),
+			}
+			for _, state := range ring.keys {
+				if strings.EqualFold(state.key.Name, source.name) {
+					info.Locked = state.locked()
+					if state.wallet != nil {
+						info.XPub = state.wallet.XPub()
+					}
+				}
+			}
+			info.Hardened = ethutils.HardenedIndex(source.path)
+			if info.Locked || info.Hardened {
+				info.XPub = ""
+			}
+			xpubs = append(xpubs, info)
+		}
+	}
+	s.lock.RUnlock()
+
+	ReturnSuccess(ctx, xpubs)
+}
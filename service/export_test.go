@@ -0,0 +1,96 @@
+package service
+
+import (
+	"encoding/json"
+	"evm-signer/service/account"
+	sTypes "evm-signer/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+)
+
+// childAddress the address of a child of an xpub, as a watch-only wallet derives it
+func childAddress(t *testing.T, xpub string, index uint32) common.Address {
+	t.Helper()
+	key, err := hdkeychain.NewKeyFromString(xpub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err = key.Child(index); err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := key.ECPubKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crypto.PubkeyToAddress(*publicKey.ToECDSA())
+}
+
+func TestListXPubsHardened(t *testing.T) {
+	source := func(name, path string) *account.Source {
+		return &account.Source{
+			Name:   name,
+			Params: map[string]interface{}{"type": "PlainMnemonic", "key": testMnemonic, "index": "0-99", "path": path, "lazy": true},
+		}
+	}
+	kr, err := account.NewKeyring(source("deposits", "m/44'/60'/0'/0/{index}"), source("ledger", "m/44'/60'/{index}'/0/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{whitelists: map[string]struct{}{"10.0.0.1": {}}}
+	if err = s.SetKeyring(kr, &LockConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{"deposits:0", "ledger:1"} {
+		if _, ok := s.LookupAccount(ref); !ok {
+			t.Fatalf("no account [ %s ]", ref)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/addresses/xpubs", nil)
+	ctx.Request.RemoteAddr = "10.0.0.1:1234"
+	s.ListXPubs(ctx)
+	var resp struct {
+		Code ErrCode            `json:"code"`
+		Data []*sTypes.XPubInfo `json:"data"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != 0 || len(resp.Data) != 2 {
+		t.Fatalf("response = %s", w.Body.String())
+	}
+	for _, info := range resp.Data {
+		hardened := info.Source == "ledger"
+		if info.Hardened != hardened || (info.XPub == "") != hardened {
+			t.Fatalf("[ %s ] hardened = %v, xpub = %q", info.Source, info.Hardened, info.XPub)
+		}
+		// the xpub is of the external chain above {index}, not of the BIP-44 account
+		wantPath := "m/44'/60'/0'/0"
+		if hardened {
+			wantPath = ""
+		}
+		if info.XPubPath != wantPath {
+			t.Fatalf("[ %s ] xpub path = %q, want %q", info.Source, info.XPubPath, wantPath)
+		}
+		if !hardened {
+			deposit, _ := s.LookupAccount("deposits:0")
+			if address := childAddress(t, info.XPub, 0); address != deposit.Address {
+				t.Fatalf("child 0 of the xpub is %s, want %s", address.Hex(), deposit.Address.Hex())
+			}
+		}
+	}
+
+	// nor do the accounts of a hardened path carry the misleading key
+	ledger, _ := s.LookupAccount("ledger:1") // ledger:0 is deposits:0
+	if ledger.XPub != "" {
+		t.Fatalf("account of a hardened path has xpub %s", ledger.XPub)
+	}
+}
@@ -1,9 +1,13 @@
 package service
 
 import (
+	"encoding/json"
 	"evm-signer/chains"
+	"evm-signer/pkg/ethutils"
+	"evm-signer/service/account"
+	"evm-signer/service/metrics"
+	"evm-signer/service/rules"
 	sTypes "evm-signer/types"
-	"encoding/json"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -11,6 +15,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"math/big"
 	"strings"
+	"time"
 )
 
 // GetSignMessage match rule, must check to
@@ -21,6 +26,10 @@ func (s *Service) GetSignMessage(ctx *gin.Context) {
 		return
 	}
 
+	if !s.limitClient(ctx) {
+		return
+	}
+
 	msgData, code, err := s.getMsgData(ctx)
 	if err != nil {
 		_msg := fmt.Sprintf("decode msgData for getSignature error: [ %s ]", err.Error())
@@ -41,6 +50,7 @@ func (s *Service) GetSignMessage(ctx *gin.Context) {
 		return
 	}
 
+	ctx.Set(chainIdKey, msgInfo.ChainId)
 	if 0 >= msgInfo.ChainId {
 		_msg := fmt.Sprintf("chainId: [ %d ] <= 0, chainId should be > 0", msgInfo.ChainId)
 		logger.Errorf(_msg)
@@ -62,28 +72,55 @@ func (s *Service) GetSignMessage(ctx *gin.Context) {
 		return
 	}
 
-	ai, ok := s.GetAccount(msgInfo.Account)
-	if !ok {
+	ai, err := s.GetAccount(msgInfo.Account, msgInfo.ChainId, OpMessage)
+	if err != nil {
+		if s.accountDenied(ctx, msgInfo.ChainId, msgInfo.Account, err) {
+			return
+		}
 		_msg := fmt.Sprintf("can't matched an account via [ %s ] account for [ %s ] messgae on [ %d ] chain_id",
 			msgInfo.Account, msgInfo.Message, msgInfo.ChainId)
 		logger.Errorf(_msg)
 		ReturnError(ctx, InvalidFormData, _msg)
 		return
 	}
+	msgInfo.Account = ai.Address.Hex() // an alias or source:index signs as the address
 
 	// match rules
-	matchRule := s.rules.GetMatchedMessage(msgInfo.ChainId, msgInfo.Message)
+	start := time.Now()
+	matchRule := s.matcher.GetMatchedMessage(msgInfo.ChainId, msgInfo.Message)
+	metrics.ObserveRuleEvaluation(ctx.FullPath(), start)
 	if matchRule == nil {
 		_msg := fmt.Sprintf("match rule via sign message was mismatched via [ %d ] chainId, [ %s ] message",
 			msgInfo.ChainId, msgInfo.Message)
 		logger.Errorf(_msg)
+		s.notifyDenied(ctx, InvalidFormData, _msg, msgInfo.ChainId, msgInfo.Account)
 		ReturnError(ctx, InvalidFormData, _msg)
 		return
 	}
-	logger.Infof("request mathed rule [ %s ]", matchRule.Name)
+	logger.Infof("request mathed rule [ %s ] at [ %s ]", matchRule.Name, matchRule.Source())
+	ctx.Set(ruleKey, matchRule.Name)
+
+	if !isApproved(ctx) && (!s.limitAccount(ctx, msgInfo.Account) || !s.limitRule(ctx, matchRule)) {
+		return
+	}
+
+	if !s.checkPolicy(ctx, msgInfo.ChainId, msgInfo.Account, matchRule, OpMessage, msgInfo.Message, nil) {
+		return
+	}
+	if matchRule.RequireApproval && !isApproved(ctx) {
+		s.park(ctx, msgData, msgInfo.ChainId, msgInfo.Account, matchRule)
+		return
+	}
 
-	s.iAccount.SetPriKey(ai.PriKey)
-	signature, err := s.iAccount.Signature(msgInfo.Message)
+	priKey := s.signingKey(ai)
+	if priKey == nil {
+		s.keyLocked(ctx, msgInfo.ChainId, msgInfo.Account)
+		return
+	}
+	defer account.ZeroKey(priKey)
+	start = time.Now()
+	signature, err := ethutils.Sign([]byte(msgInfo.Message), priKey)
+	metrics.ObserveSigning(ctx.FullPath(), start)
 	if err != nil {
 		_msg := fmt.Sprintf("get signature for [ %s ] message on [ %d ] chain error: [ %s ]",
 			msgInfo.Message, msgInfo.ChainId, err.Error())
@@ -98,6 +135,7 @@ func (s *Service) GetSignMessage(ctx *gin.Context) {
 
 	logger.Infof("request ip: [ %s ], account: [ %s ], chain_id: [ %d ], message: [ %s ], signed message: [ %s ]",
 		ctx.ClientIP(), msgInfo.Account, msgInfo.ChainId, msgInfo.Message, data.Data)
+	s.notifySigned(ctx, msgInfo.ChainId, msgInfo.Account, matchRule, gin.H{"message": msgInfo.Message})
 	ctx.AbortWithStatusJSON(200, data)
 }
 
@@ -109,6 +147,10 @@ func (s *Service) GetAddress(ctx *gin.Context) {
 		return
 	}
 
+	if !s.limitClient(ctx) {
+		return
+	}
+
 	msgData, code, err := s.getMsgData(ctx)
 	if err != nil {
 		_msg := fmt.Sprintf("parser msgData for getAddress error: [ %s ]", err.Error())
@@ -126,6 +168,7 @@ func (s *Service) GetAddress(ctx *gin.Context) {
 		return
 	}
 
+	ctx.Set(chainIdKey, msgInfo.ChainId)
 	if 0 >= msgInfo.ChainId {
 		_msg := fmt.Sprintf("chainId: [ %d ] <= 0, chainId should be > 0", msgInfo.ChainId)
 		logger.Errorf(_msg)
@@ -140,11 +183,21 @@ func (s *Service) GetAddress(ctx *gin.Context) {
 		return
 	}
 
-	// return address
-	ai, ok := s.GetAccountList(msgInfo.Index)
+	// return address, of the account reference or else of the index of the first source
+	var ai *sTypes.Account
+	var ok bool
+	if msgInfo.Account != "" {
+		ai, ok = s.LookupAccount(msgInfo.Account)
+	} else {
+		ai, ok = s.GetAccountList(msgInfo.Index)
+	}
 	if !ok {
 		_msg := fmt.Sprintf("can't matched an account via [ %d ] account index on [ %d ] chain id",
 			msgInfo.Index, msgInfo.ChainId)
+		if msgInfo.Account != "" {
+			_msg = fmt.Sprintf("can't matched an account via [ %s ] account on [ %d ] chain id",
+				msgInfo.Account, msgInfo.ChainId)
+		}
 		logger.Errorf(_msg)
 		ReturnError(ctx, InvalidFormData, _msg)
 		return
@@ -154,8 +207,8 @@ func (s *Service) GetAddress(ctx *gin.Context) {
 		Data: ai.Address.String(),
 	}
 
-	logger.Infof("request ip: [ %s ], chain_id: [ %d ], account index: [ %d ], resp account: [ %s ]",
-		ctx.ClientIP(), msgInfo.ChainId, msgInfo.Index, data.Data)
+	logger.Infof("request ip: [ %s ], chain_id: [ %d ], account: [ %s ], account index: [ %d ], resp account: [ %s ]",
+		ctx.ClientIP(), msgInfo.ChainId, msgInfo.Account, msgInfo.Index, data.Data)
 	ctx.AbortWithStatusJSON(200, data)
 }
 
@@ -168,6 +221,10 @@ func (s *Service) GetSign712(ctx *gin.Context) {
 		return
 	}
 
+	if !s.limitClient(ctx) {
+		return
+	}
+
 	msgData, code, err := s.getMsgData(ctx)
 	if err != nil {
 		_msg := fmt.Sprintf("parse msg error: [ %s ]", err.Error())
@@ -208,6 +265,7 @@ func (s *Service) GetSign712(ctx *gin.Context) {
 		return
 	}
 
+	ctx.Set(chainIdKey, msgInfo.ChainId)
 	if msgInfo.ChainId <= 0 {
 		_msg := fmt.Sprintf("chainId: [ %d ] <= 0, chainId should be > 0", msgInfo.ChainId)
 		logger.Errorf(_msg)
@@ -230,23 +288,43 @@ func (s *Service) GetSign712(ctx *gin.Context) {
 		return
 	}
 
-	ai, ok := s.GetAccount(msgInfo.Account)
-	if !ok {
+	ai, err := s.GetAccount(msgInfo.Account, msgInfo.ChainId, OpEIP712)
+	if err != nil {
+		if s.accountDenied(ctx, msgInfo.ChainId, msgInfo.Account, err) {
+			return
+		}
 		_msg := fmt.Sprintf("[ %s ] account not exist", msgInfo.Account)
 		logger.Errorf(_msg)
 		ReturnError(ctx, InvalidFormData, _msg)
 		return
 	}
+	msgInfo.Account = ai.Address.Hex() // an alias or source:index signs as the address
 
 	// match rule
-	matchRule := s.rules.GetMatchedEip712(msgInfo.ChainId, &eip712Data)
+	start := time.Now()
+	matchRule := s.matcher.GetMatchedEip712(msgInfo.ChainId, &eip712Data)
+	metrics.ObserveRuleEvaluation(ctx.FullPath(), start)
 	if matchRule == nil {
 		_msg := "match rule via transaction was mismatched"
 		logger.Errorf(_msg)
+		s.notifyDenied(ctx, InvalidFormData, _msg, msgInfo.ChainId, msgInfo.Account)
 		ReturnError(ctx, InvalidFormData, _msg)
 		return
 	}
-	logger.Infof("request mathed rule [ %s ]", matchRule.Name)
+	logger.Infof("request mathed rule [ %s ] at [ %s ]", matchRule.Name, matchRule.Source())
+	ctx.Set(ruleKey, matchRule.Name)
+
+	if !isApproved(ctx) && (!s.limitAccount(ctx, msgInfo.Account) || !s.limitRule(ctx, matchRule)) {
+		return
+	}
+
+	if !s.checkPolicy(ctx, msgInfo.ChainId, msgInfo.Account, matchRule, OpEIP712, eip712Data, nil) {
+		return
+	}
+	if matchRule.RequireApproval && !isApproved(ctx) {
+		s.park(ctx, msgData, msgInfo.ChainId, msgInfo.Account, matchRule)
+		return
+	}
 
 	hashData, _, err := apitypes.TypedDataAndHash(eip712Data)
 	if err != nil {
@@ -257,8 +335,13 @@ func (s *Service) GetSign712(ctx *gin.Context) {
 		return
 	}
 
-	key := priKey2Str(ai.PriKey)
-	chain, err := chains.GetChain(chainConfig.ChainId, chainConfig.ChainType, key)
+	priKey := s.signingKey(ai)
+	if priKey == nil {
+		s.keyLocked(ctx, msgInfo.ChainId, msgInfo.Account)
+		return
+	}
+	defer account.ZeroKey(priKey)
+	chain, err := chains.GetChain(chainConfig.ChainId, chainConfig.ChainType, priKey)
 	if err != nil {
 		_msg := fmt.Sprintf("[ %d ] chain config find error: [ %s ]", chainConfig.ChainId, err.Error())
 		logger.Errorf(_msg)
@@ -266,7 +349,9 @@ func (s *Service) GetSign712(ctx *gin.Context) {
 		return
 	}
 
+	start = time.Now()
 	signature, err := chain.Sign712(hashData)
+	metrics.ObserveSigning(ctx.FullPath(), start)
 	if err != nil {
 		_msg := fmt.Sprintf("get chain sign for [ %s ] transaction error: [ %s ]",
 			hexutil.Encode(hashData), err.Error())
@@ -281,6 +366,10 @@ func (s *Service) GetSign712(ctx *gin.Context) {
 
 	logger.Infof("[EIP712] request ip: [ %s ], chain_id: [ %d ], account: [ %s ], messgae: [ %s ], signed data: [ %s ]",
 		ctx.ClientIP(), msgInfo.ChainId, msgInfo.Account, msgInfo.Data, sign.Signature)
+	s.notifySigned(ctx, msgInfo.ChainId, msgInfo.Account, matchRule, gin.H{
+		"primary_type": eip712Data.PrimaryType,
+		"hash":         hexutil.Encode(hashData),
+	})
 	ctx.AbortWithStatusJSON(200, sign)
 }
 
@@ -291,6 +380,10 @@ func (s *Service) GetSign(ctx *gin.Context) {
 		ReturnError(ctx, IllegalAccess, _msg)
 		return
 	}
+
+	if !s.limitClient(ctx) {
+		return
+	}
 	msgData, code, err := s.getMsgData(ctx)
 	if err != nil {
 		_msg := fmt.Sprintf("parse msg error: [ %s ]", err.Error())
@@ -308,6 +401,7 @@ func (s *Service) GetSign(ctx *gin.Context) {
 		return
 	}
 
+	ctx.Set(chainIdKey, msgInfo.ChainId)
 	if msgInfo.ChainId <= 0 {
 		_msg := fmt.Sprintf("chainId: [ %d ] <= 0, chainId should be > 0", msgInfo.ChainId)
 		logger.Errorf(_msg)
@@ -337,13 +431,17 @@ func (s *Service) GetSign(ctx *gin.Context) {
 		return
 	}
 
-	ai, ok := s.GetAccount(msgInfo.Account)
-	if !ok {
+	ai, err := s.GetAccount(msgInfo.Account, msgInfo.ChainId, OpTransaction)
+	if err != nil {
+		if s.accountDenied(ctx, msgInfo.ChainId, msgInfo.Account, err) {
+			return
+		}
 		_msg := fmt.Sprintf("[ %s ] account not exist", msgInfo.Account)
 		logger.Errorf(_msg)
 		ReturnError(ctx, InvalidFormData, _msg)
 		return
 	}
+	msgInfo.Account = ai.Address.Hex() // an alias or source:index signs as the address
 
 	// parse tx
 	tx := new(sTypes.Transaction)
@@ -356,16 +454,78 @@ func (s *Service) GetSign(ctx *gin.Context) {
 	}
 	tx.From = strings.ToLower(msgInfo.Account)
 
+	// the reserved nonce is released unless the transaction is signed
+	var reservation *NonceReservation
+	if s.nonces != nil {
+		defer func() { s.nonces.Release(reservation) }()
+	}
+	if s.nonces != nil && s.nonces.AutoAssign() && tx.Nonce == "" {
+		reservation, err = s.nonces.Reserve(msgInfo.Account, msgInfo.ChainId, tx)
+		if err != nil {
+			_msg := fmt.Sprintf("assign nonce error: [ %s ]", err.Error())
+			logger.Errorf(_msg)
+			s.notifyDenied(ctx, NonceError, _msg, msgInfo.ChainId, msgInfo.Account)
+			ReturnError(ctx, NonceError, _msg)
+			return
+		}
+		txJson, err := json.Marshal(tx)
+		if err != nil {
+			_msg := fmt.Sprintf("marshal transaction error: [ %s ]", err.Error())
+			logger.Errorf(_msg)
+			ReturnError(ctx, InternalError, _msg)
+			return
+		}
+		msgInfo.Transaction = string(txJson)
+		logger.Infof("assigned nonce [ %s ] for [ %s ] account on [ %d ] chain", tx.Nonce, msgInfo.Account, msgInfo.ChainId)
+	}
+
 	// match rule
-	matchRule := s.rules.GetMatched(msgInfo.ChainId, tx)
+	start := time.Now()
+	matchRule := s.matcher.GetMatched(msgInfo.ChainId, tx)
+	metrics.ObserveRuleEvaluation(ctx.FullPath(), start)
 	if matchRule == nil {
 		_msg := fmt.Sprintf("match rule via [ %s ] transaction for [ %s ] account on [ %d ] chainId was mismatched",
 			msgInfo.Transaction, msgInfo.Account, msgInfo.ChainId)
 		logger.Errorf(_msg)
+		s.notifyDenied(ctx, InvalidFormData, _msg, msgInfo.ChainId, msgInfo.Account)
 		ReturnError(ctx, InvalidFormData, _msg)
 		return
 	}
-	logger.Infof("request mathed rule [ %s ]", matchRule.Name)
+	logger.Infof("request mathed rule [ %s ] at [ %s ]", matchRule.Name, matchRule.Source())
+	ctx.Set(ruleKey, matchRule.Name)
+
+	if !isApproved(ctx) && (!s.limitAccount(ctx, msgInfo.Account) || !s.limitRule(ctx, matchRule)) {
+		return
+	}
+
+	if err = chainConfig.CheckCeilings(matchRule, tx); err != nil {
+		_msg := fmt.Sprintf("[ %s ] transaction for [ %s ] account was forbidden: [ %s ]",
+			msgInfo.Transaction, msgInfo.Account, err.Error())
+		logger.Errorf(_msg)
+		s.notifyDenied(ctx, ForbiddenError, _msg, msgInfo.ChainId, msgInfo.Account)
+		ReturnError(ctx, ForbiddenError, _msg)
+		return
+	}
+
+	if !s.checkPolicy(ctx, msgInfo.ChainId, msgInfo.Account, matchRule, OpTransaction,
+		rules.TxView(tx), matchRule.DecodeCall(tx.Input)) {
+		return
+	}
+	if matchRule.RequireApproval && !isApproved(ctx) {
+		s.park(ctx, msgData, msgInfo.ChainId, msgInfo.Account, matchRule)
+		return
+	}
+
+	if s.nonces != nil && reservation == nil {
+		if reservation, err = s.nonces.Reserve(msgInfo.Account, msgInfo.ChainId, tx); err != nil {
+			_msg := fmt.Sprintf("[ %s ] transaction for [ %s ] account on [ %d ] chainId was rejected: [ %s ]",
+				msgInfo.Transaction, msgInfo.Account, msgInfo.ChainId, err.Error())
+			logger.Errorf(_msg)
+			s.notifyDenied(ctx, NonceError, _msg, msgInfo.ChainId, msgInfo.Account)
+			ReturnError(ctx, NonceError, _msg)
+			return
+		}
+	}
 
 	// convert
 	msgInfo.Transaction, err = txParse(fmt.Sprintf("%d", msgInfo.ChainId), msgInfo.Transaction)
@@ -376,8 +536,13 @@ func (s *Service) GetSign(ctx *gin.Context) {
 		return
 	}
 
-	key := priKey2Str(ai.PriKey)
-	chain, err := chains.GetChain(chainConfig.ChainId, chainConfig.ChainType, key)
+	priKey := s.signingKey(ai)
+	if priKey == nil {
+		s.keyLocked(ctx, msgInfo.ChainId, msgInfo.Account)
+		return
+	}
+	defer account.ZeroKey(priKey)
+	chain, err := chains.GetChain(chainConfig.ChainId, chainConfig.ChainType, priKey)
 	if err != nil {
 		_msg := fmt.Sprintf("[ %d ] chain config find error: [ %s ]", chainConfig.ChainId, err.Error())
 		logger.Errorf(_msg)
@@ -386,7 +551,9 @@ func (s *Service) GetSign(ctx *gin.Context) {
 	}
 
 	// normal sign
+	start = time.Now()
 	signature, err := chain.Sign(msgInfo.Transaction)
+	metrics.ObserveSigning(ctx.FullPath(), start)
 	if err != nil {
 		_msg := fmt.Sprintf("get chain sign for [ %s ] transaction error: [ %s ]", tx.Hash, err.Error())
 		logger.Errorf(_msg)
@@ -434,6 +601,15 @@ func (s *Service) GetSign(ctx *gin.Context) {
 		return
 	}
 
+	if reservation != nil {
+		if err = s.nonces.Commit(reservation); err != nil {
+			_msg := fmt.Sprintf("[ %d ] chain record nonce error: [ %s ]", msgInfo.ChainId, err.Error())
+			logger.Errorf(_msg)
+			ReturnError(ctx, InternalError, _msg)
+			return
+		}
+	}
+
 	sign := sTypes.Sign{
 		Signature: signature,
 		TxData:    string(marshalJSON),
@@ -442,6 +618,12 @@ func (s *Service) GetSign(ctx *gin.Context) {
 
 	logger.Infof("[Sign Transaction] request ip: [ %s ], chain_id: [ %d ], account: [ %s ], Transaction: [ %s ], signed data: [ %s ]",
 		ctx.ClientIP(), msgInfo.ChainId, msgInfo.Account, msgInfo.Transaction, sign.Signature)
+	s.notifySigned(ctx, msgInfo.ChainId, msgInfo.Account, matchRule, gin.H{
+		"tx_hash": txData.Hash().Hex(),
+		"to":      tx.To,
+		"value":   tx.Value,
+		"nonce":   txData.Nonce(),
+	})
 	ctx.AbortWithStatusJSON(200, sign)
 }
 
@@ -457,14 +639,15 @@ func (s *Service) getMsgData(ctx *gin.Context) ([]byte, ErrCode, error) {
 func (s *Service) CheckIp(ctx *gin.Context) error {
 	err := s.checkIP(ctx.ClientIP())
 	if err != nil {
+		s.notifyAuthFailed(ctx, err.Error())
 		return err
 	}
 	return nil
 }
 
 func (s *Service) checkIP(ip string) error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 
 	if _, ok := s.whitelists[ip]; !ok {
 		return ErrIllegalIP
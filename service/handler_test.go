@@ -0,0 +1,88 @@
+package service
+
+import (
+	"encoding/json"
+	"evm-signer/service/account"
+	"evm-signer/service/rules"
+	sTypes "evm-signer/types"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+)
+
+func signMessage(s *Service, ref, message string) (string, error) {
+	data, _ := json.Marshal(map[string]interface{}{"chain_id": 1, "account": ref, "message": message})
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/sign/message",
+		strings.NewReader(url.Values{"data": {string(data)}}.Encode()))
+	ctx.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx.Request.RemoteAddr = "10.0.0.1:1234"
+	s.GetSignMessage(ctx)
+	if w.Code != http.StatusOK {
+		return "", fmt.Errorf("status %d: %s", w.Code, w.Body.String())
+	}
+	var resp sTypes.Data
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		return "", err
+	}
+	return resp.Data, nil
+}
+
+func TestSignMessageConcurrent(t *testing.T) {
+	kr, err := account.NewKeyring(&account.Source{
+		Name:   "login",
+		Params: map[string]interface{}{"type": "PlainMnemonic", "key": testMnemonic, "index": "0-9", "lazy": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{whitelists: map[string]struct{}{"10.0.0.1": {}}}
+	if err = s.SetKeyring(kr, &LockConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	s.SetRules(rules.Rules{{Name: "login", ChainId: 1, Conditions: &rules.Conditions{
+		{Field: rules.MessageField, Symbol: rules.ContainsSymbol, Value: "login"},
+	}}})
+
+	// requests of different accounts at the same time sign with their own key
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			ref := fmt.Sprintf("login:%d", index%4)
+			message := fmt.Sprintf("login %d", index)
+			signature, err := signMessage(s, ref, message)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			sig, err := hexutil.Decode(signature)
+			if err != nil || len(sig) != 65 {
+				t.Errorf("signature %s", signature)
+				return
+			}
+			sig[64] -= 27
+			publicKey, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ai, _ := s.LookupAccount(ref)
+			if crypto.PubkeyToAddress(*publicKey) != ai.Address {
+				t.Errorf("[ %s ] signed by %s, want %s", ref, crypto.PubkeyToAddress(*publicKey).Hex(), ai.Address.Hex())
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,501 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"evm-signer/pkg/strutil"
+	"evm-signer/service/account"
+	"evm-signer/service/webhook"
+	"evm-signer/types"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const autoLockInterval = time.Second
+
+type LockConfig struct {
+	StartLocked bool   `mapstructure:"start_locked"` // start with every key locked, unlock them over the admin api
+	IdleTimeout string `mapstructure:"idle_timeout"` // re-lock a key unused for this long, eg. 30m, empty never
+}
+
+// keyState the lock state of a key, the accounts and the wallet are nil while it is locked
+type keyState struct {
+	key        *account.Key
+	accounts   []*types.Account
+	wallet     *account.HDWallet // of a lazy EncryptedMnemonic
+	unlockedAt time.Time
+	lastUsed   atomic.Int64 // unix nano of the last signing
+}
+
+func (k *keyState) locked() bool {
+	return k.accounts == nil && k.wallet == nil
+}
+
+type keyring struct {
+	unlockLock  sync.Mutex // one unlock or lock at a time, decrypting doesn't hold the service lock
+	plain       []*types.Account
+	wallets     []*account.HDWallet // of the lazy PlainMnemonic sources
+	keys        []*keyState
+	keyForRef   map[string]*keyState            // by address, source:index and alias, like the accounts
+	aliases     map[string]string               // alias: source:index
+	refAliases  map[string][]string             // source:index: its aliases
+	allocatable map[string]*allocatable         // by lower case source name
+	mnemonics   []*mnemonicSource               // the PlainMnemonic and EncryptedMnemonic sources
+	order       map[string]int                  // lower case source name: its position in the config
+	restricts   map[string]*account.Restriction // by source:index, else by source name
+	first       string                          // the first source, whose indices /v1/address looks up
+	idleTimeout time.Duration
+}
+
+// SetKeyring loads the accounts of the keyring, its keys are unlocked now unless the config starts them locked
+func (s *Service) SetKeyring(kr *account.Keyring, lockConfig *LockConfig) error {
+	ring := &keyring{
+		plain:       kr.Accounts,
+		wallets:     kr.Wallets,
+		aliases:     make(map[string]string),
+		refAliases:  make(map[string][]string),
+		restricts:   make(map[string]*account.Restriction),
+		allocatable: make(map[string]*allocatable),
+		order:       make(map[string]int),
+		first:       kr.Sources[0].Name,
+	}
+	for position, source := range kr.Sources {
+		ring.order[strings.ToLower(source.Name)] = position
+		if _source := newMnemonicSource(source); _source != nil {
+			ring.mnemonics = append(ring.mnemonics, _source)
+		}
+		if source.Allocate {
+			indexRange, _ := source.Params["index"].(string)
+			ranges, err := strutil.ParseRanges(indexRange)
+			if err != nil {
+				return fmt.Errorf("invalid index of [ %s ]: %s", source.Name, err)
+			}
+			ring.allocatable[strings.ToLower(source.Name)] = &allocatable{name: source.Name, indexRange: indexRange, ranges: ranges}
+		}
+		for alias, index := range source.Aliases {
+			ref := accountRef(source.Name, index)
+			ring.aliases[strings.ToLower(alias)] = ref
+			ring.refAliases[ref] = append(ring.refAliases[ref], strings.ToLower(alias))
+		}
+		if source.Restriction != nil {
+			ring.restricts[strings.ToLower(source.Name)] = source.Restriction
+		}
+		for index, restriction := range source.Restrictions {
+			ring.restricts[accountRef(source.Name, index)] = restriction
+		}
+	}
+	if lockConfig.IdleTimeout != "" {
+		idleTimeout, err := time.ParseDuration(lockConfig.IdleTimeout)
+		if err != nil || idleTimeout < 0 {
+			return fmt.Errorf("invalid lock idle_timeout %s", lockConfig.IdleTimeout)
+		}
+		ring.idleTimeout = idleTimeout
+	}
+
+	unlocked := make(map[*account.Key]*account.Unlocked)
+	if lockConfig.StartLocked {
+		kr.Forget()
+	} else {
+		var err error
+		if unlocked, err = kr.UnlockAll(); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	for _, key := range kr.Keys {
+		state := &keyState{key: key}
+		if _unlocked, ok := unlocked[key]; ok {
+			state.accounts, state.wallet = _unlocked.Accounts, _unlocked.Wallet
+		}
+		if !state.locked() {
+			state.unlockedAt = now
+			state.lastUsed.Store(now.UnixNano())
+		}
+		ring.keys = append(ring.keys, state)
+	}
+
+	s.lock.Lock()
+	s.keyring = ring
+	s.loadAccounts()
+	var unresolved []string
+	for alias := range ring.aliases {
+		if _, ok := s.accountForRef[alias]; !ok && ring.keyOfRef(alias) == nil && ring.walletOf(alias) == nil {
+			unresolved = append(unresolved, alias)
+		}
+	}
+	s.lock.Unlock()
+
+	logger.Infof("[Account] loaded [ %d ] sources, [ %d ] plain accounts, [ %d ] lazy mnemonics and [ %d ] keys",
+		len(kr.Sources), len(ring.plain), len(ring.wallets), len(ring.keys))
+	if len(unresolved) != 0 {
+		sort.Strings(unresolved)
+		logger.Warnf("[Account] aliases [ %s ] name no account of their source", strings.Join(unresolved, ", "))
+	}
+	for _, state := range ring.keys {
+		status := "unlocked"
+		if state.locked() {
+			status = "locked"
+		}
+		if state.wallet != nil {
+			logger.Infof("[Account] [ %s ] %s: derived on demand, %s", state.key.Source, state.key.Type, status)
+			continue
+		}
+		logger.Infof("[Account] [ %s ] %s: [ %d ] accounts, %s", state.key.Source, state.key.Type, len(state.key.Addresses), status)
+	}
+	if lockConfig.StartLocked && len(ring.keys) != 0 {
+		logger.Warnf("[Lock] started with [ %d ] keys locked, unlock them with `signer unlock`", len(ring.keys))
+	}
+	if ring.idleTimeout > 0 && len(ring.keys) != 0 {
+		logger.Infof("[Lock] keys unused for [ %s ] are locked again", ring.idleTimeout)
+		go s.autoLock()
+	}
+	return nil
+}
+
+// keyOf the key of a source or an account reference
+func (s *Service) keyOf(source, ref string) (*keyState, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.keyring == nil {
+		return nil, fmt.Errorf("no keys to lock or unlock")
+	}
+	if ref != "" {
+		state := s.keyring.keyOfRef(ref)
+		if state == nil {
+			return nil, fmt.Errorf("[ %s ] is not an account of a key", ref)
+		}
+		return state, nil
+	}
+	for _, state := range s.keyring.keys {
+		if strings.EqualFold(state.key.Source, source) {
+			return state, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key source [ %s ]", source)
+}
+
+// unlockKey decrypts the key with the passphrase, an empty one is read from the configured source
+func (s *Service) unlockKey(state *keyState, pass string) error {
+	s.keyring.unlockLock.Lock()
+	defer s.keyring.unlockLock.Unlock()
+
+	unlocked, err := state.key.Unlock(pass)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	addresses := make([]common.Address, 0, len(unlocked.Accounts))
+	for _, _account := range unlocked.Accounts {
+		addresses = append(addresses, _account.Address)
+	}
+
+	s.lock.Lock()
+	state.key.Addresses = addresses
+	state.accounts, state.wallet = unlocked.Accounts, unlocked.Wallet
+	state.unlockedAt = now
+	state.lastUsed.Store(now.UnixNano())
+	s.loadAccounts()
+	s.lock.Unlock()
+	if state.wallet != nil {
+		s.preload(state.wallet, state)
+	}
+	return nil
+}
+
+// lockKey drops the private keys of the key, false when it was locked already or, with a non zero idleSince,
+// when it was used since
+func (s *Service) lockKey(state *keyState, idleSince int64) bool {
+	s.keyring.unlockLock.Lock()
+	defer s.keyring.unlockLock.Unlock()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if state.locked() || idleSince != 0 && state.lastUsed.Load() >= idleSince {
+		return false
+	}
+	accounts := state.accounts
+	if state.wallet != nil {
+		accounts = state.wallet.Cached()
+	}
+	for _, _account := range accounts {
+		wipeKey(_account)
+	}
+	state.accounts, state.wallet = nil, nil
+	state.unlockedAt = time.Time{}
+	s.loadAccounts()
+	return true
+}
+
+// wipeKey zeroes the private key of an account and drops it, a request still holding the account finds no
+// key to sign with. The caller holds the service lock
+func wipeKey(_account *types.Account) {
+	if _account.PriKey != nil {
+		account.ZeroKey(_account.PriKey)
+		_account.PriKey = nil
+	}
+}
+
+// signingKey a copy of the private key of an account to sign with, or the key of a lazy mnemonic account
+// derived again, nil when its key was locked since the account was looked up. The caller zeroes it once signed
+func (s *Service) signingKey(_account *types.Account) *ecdsa.PrivateKey {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if _account.PriKey == nil {
+		if !s.hasKey(_account) {
+			return nil
+		}
+		lazy := s.keyring.walletOf(accountRef(_account.Name, _account.Index))
+		key, err := lazy.wallet.PrivateKey(lazy.index)
+		if err != nil {
+			logger.Errorf("[Account] derive [ %s ] error: %s", accountRef(_account.Name, _account.Index), err)
+			return nil
+		}
+		return key
+	}
+	key := *_account.PriKey
+	key.D = new(big.Int).Set(_account.PriKey.D)
+	return &key
+}
+
+// keyLocked answers AccountLocked when the key of an account was locked between its lookup and the signing
+func (s *Service) keyLocked(ctx *gin.Context, chainId int64, ref string) {
+	_msg := fmt.Sprintf("[ %s ] account is locked, unlock it with `signer unlock`", ref)
+	logger.Errorf(_msg)
+	s.notifyDenied(ctx, AccountLocked, _msg, chainId, ref)
+	ReturnError(ctx, AccountLocked, _msg)
+}
+
+// touch records the signing use of an account, which delays the idle lock of its key
+func (s *Service) touch(ref string) {
+	if s.keyring == nil {
+		return
+	}
+	if state := s.keyring.keyOfRef(ref); state != nil {
+		state.lastUsed.Store(time.Now().UnixNano())
+	}
+}
+
+// isLocked whether the account reference belongs to a locked key
+func (s *Service) isLocked(ref string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.keyring == nil {
+		return false
+	}
+	state := s.keyring.keyOfRef(ref)
+	return state != nil && state.locked()
+}
+
+// accountDenied answers AccountRestricted when the account of a signing request may not sign it, or
+// AccountLocked when it is locked. False leaves an unknown account to the caller
+func (s *Service) accountDenied(ctx *gin.Context, chainId int64, ref string, err error) bool {
+	if errors.Is(err, ErrAccountRestricted) {
+		_msg := fmt.Sprintf("[ %s ] %s", ref, err)
+		logger.Errorf(_msg)
+		s.notifyDenied(ctx, AccountRestricted, _msg, chainId, ref)
+		ReturnError(ctx, AccountRestricted, _msg)
+		return true
+	}
+	if !s.isLocked(ref) {
+		return false
+	}
+	s.keyLocked(ctx, chainId, ref)
+	return true
+}
+
+// autoLock locks the keys unused for the idle timeout
+func (s *Service) autoLock() {
+	ticker := time.NewTicker(autoLockInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		deadline := time.Now().Add(-s.keyring.idleTimeout).UnixNano()
+		for _, state := range s.keyring.keys {
+			s.lock.RLock()
+			idle := !state.locked() && state.lastUsed.Load() < deadline
+			s.lock.RUnlock()
+			if idle && s.lockKey(state, deadline) {
+				logger.Infof("[Lock] key [ %s ] locked after [ %s ] idle", state.key.Source, s.keyring.idleTimeout)
+				s.notifier.Emit(webhook.KeyLocked, gin.H{
+					"source": state.key.Source,
+					"reason": "idle",
+				})
+			}
+		}
+	}
+}
+
+type keyInfo struct {
+	Name       string     `json:"name"` // the account source
+	Source     string     `json:"source"`
+	Type       string     `json:"type"`
+	Index      int64      `json:"index"`
+	Locked     bool       `json:"locked"`
+	PassSource bool       `json:"pass_source"` // an unlock without passphrase reads the configured source
+	Addresses  []string   `json:"addresses"`
+	UnlockedAt *time.Time `json:"unlocked_at,omitempty"`
+	LastUsed   *time.Time `json:"last_used,omitempty"`
+	LocksAt    *time.Time `json:"locks_at,omitempty"` // the idle lock unless the key is used before
+}
+
+func (s *Service) keyInfo(state *keyState) *keyInfo {
+	info := &keyInfo{
+		Name:       state.key.Name,
+		Source:     state.key.Source,
+		Type:       string(state.key.Type),
+		Index:      state.key.Index,
+		Locked:     state.locked(),
+		PassSource: state.key.CanUnlock(),
+		Addresses:  make([]string, 0, len(state.key.Addresses)),
+	}
+	for _, address := range state.key.Addresses {
+		info.Addresses = append(info.Addresses, address.Hex())
+	}
+	if !info.Locked {
+		unlockedAt := state.unlockedAt
+		lastUsed := time.Unix(0, state.lastUsed.Load())
+		info.UnlockedAt, info.LastUsed = &unlockedAt, &lastUsed
+		if s.keyring.idleTimeout > 0 {
+			locksAt := lastUsed.Add(s.keyring.idleTimeout)
+			info.LocksAt = &locksAt
+		}
+	}
+	return info
+}
+
+// ListKeys the keys and their lock state
+func (s *Service) ListKeys(ctx *gin.Context) {
+	keys := make([]*keyInfo, 0)
+	s.lock.RLock()
+	if s.keyring != nil {
+		for _, state := range s.keyring.keys {
+			keys = append(keys, s.keyInfo(state))
+		}
+	}
+	s.lock.RUnlock()
+	ReturnSuccess(ctx, keys)
+}
+
+type lockRequest struct {
+	Source     string `json:"source"`     // the config path of the key, eg. account or account.keys.2
+	Account    string `json:"account"`    // or an account of the key, its address, source:index or alias
+	Passphrase string `json:"passphrase"` // unlock only, empty reads the configured passphrase source
+}
+
+func bindLockRequest(ctx *gin.Context) (*lockRequest, error) {
+	req := new(lockRequest)
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(req); err != nil {
+			return nil, fmt.Errorf("invalid request body: %s", err)
+		}
+	}
+	return req, nil
+}
+
+// Unlock unlocks a key with the passphrase of the request or of its configured source, a passphrase is
+// refused unless the request came over TLS
+func (s *Service) Unlock(ctx *gin.Context) {
+	admin := ctx.GetString(adminKey)
+	req, err := bindLockRequest(ctx)
+	if err == nil && req.Passphrase != "" && ctx.Request.TLS == nil {
+		// never read a passphrase off a plain http connection
+		_msg := "[Lock] unlock error: a passphrase is only accepted over https, enable listen.ssl_enable " +
+			"or unlock with the passphrase source of the key"
+		logger.Errorf(_msg)
+		s.notifyAuthFailed(ctx, "unlock passphrase over plain http")
+		ReturnError(ctx, IllegalAccess, _msg)
+		return
+	}
+	if err == nil && req.Source == "" && req.Account == "" {
+		err = fmt.Errorf("source or account is required")
+	}
+	var state *keyState
+	if err == nil {
+		state, err = s.keyOf(req.Source, req.Account)
+	}
+	if err == nil && req.Passphrase == "" && !state.key.CanUnlock() {
+		err = fmt.Errorf("passphrase is required, [ %s ] has no passphrase source", state.key.Source)
+	}
+	if err != nil {
+		_msg := fmt.Sprintf("[Lock] unlock error: %s", err)
+		logger.Errorf(_msg)
+		ReturnError(ctx, ParamError, _msg)
+		return
+	}
+
+	if err = s.unlockKey(state, req.Passphrase); err != nil {
+		_msg := fmt.Sprintf("[Lock] admin: [ %s ], unlock error: %s", admin, err)
+		logger.Errorf(_msg)
+		s.notifyAuthFailed(ctx, fmt.Sprintf("unlock %s failed", state.key.Source))
+		ReturnError(ctx, AuthError, _msg)
+		return
+	}
+
+	s.lock.RLock()
+	info := s.keyInfo(state)
+	s.lock.RUnlock()
+	logger.Infof("[Lock] admin: [ %s ], key [ %s ] unlocked, accounts: [ %d ]", admin, info.Source, len(info.Addresses))
+	s.notifier.Emit(webhook.KeyUnlocked, gin.H{
+		"source": info.Source,
+		"admin":  admin,
+	})
+	ReturnSuccess(ctx, info)
+}
+
+// Lock locks a key, or every key when the request names none
+func (s *Service) Lock(ctx *gin.Context) {
+	admin := ctx.GetString(adminKey)
+	req, err := bindLockRequest(ctx)
+	var states []*keyState
+	if err == nil {
+		if req.Source != "" || req.Account != "" {
+			var state *keyState
+			if state, err = s.keyOf(req.Source, req.Account); err == nil {
+				states = append(states, state)
+			}
+		} else if s.keyring != nil {
+			states = s.keyring.keys
+		}
+	}
+	if err != nil {
+		_msg := fmt.Sprintf("[Lock] lock error: %s", err)
+		logger.Errorf(_msg)
+		ReturnError(ctx, ParamError, _msg)
+		return
+	}
+
+	locked := make([]string, 0, len(states))
+	for _, state := range states {
+		if s.lockKey(state, 0) {
+			locked = append(locked, state.key.Source)
+			logger.Infof("[Lock] admin: [ %s ], key [ %s ] locked", admin, state.key.Source)
+			s.notifier.Emit(webhook.KeyLocked, gin.H{
+				"source": state.key.Source,
+				"admin":  admin,
+				"reason": "admin",
+			})
+		}
+	}
+	ReturnSuccess(ctx, gin.H{"locked": locked})
+}
+
+// lockedKeys the number of keys and of the locked ones
+func (s *Service) lockedKeys() (int, int) {
+	if s.keyring == nil {
+		return 0, 0
+	}
+	locked := 0
+	for _, state := range s.keyring.keys {
+		if state.locked() {
+			locked++
+		}
+	}
+	return len(s.keyring.keys), locked
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"evm-signer/service/account"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_keystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const testKeystorePass = "secret"
+
+// newTestLockService a service with a locked keystore source named treasury
+func newTestLockService(t *testing.T, idleTimeout string) *Service {
+	t.Helper()
+	priKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyJson, err := _keystore.EncryptKey(&_keystore.Key{
+		Id:         uuid.New(),
+		Address:    crypto.PubkeyToAddress(priKey.PublicKey),
+		PrivateKey: priKey,
+	}, testKeystorePass, _keystore.LightScryptN, _keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "treasury.json")
+	if err = os.WriteFile(path, keyJson, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	kr, err := account.NewKeyring(&account.Source{
+		Name:   "treasury",
+		Params: map[string]interface{}{"type": "Keystore", "key": path},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{}
+	if err = s.SetKeyring(kr, &LockConfig{StartLocked: true, IdleTimeout: idleTimeout}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func unlockRequest(s *Service, body string, overTLS bool) (ErrCode, error) {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/admin/v1/unlock", bytes.NewBufferString(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	if overTLS {
+		ctx.Request.TLS = &tls.ConnectionState{}
+	}
+	s.Unlock(ctx)
+	var resp ResponseMsg
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp.Code, err
+}
+
+func TestUnlockPassphrase(t *testing.T) {
+	s := newTestLockService(t, "")
+	tests := []struct {
+		name     string
+		pass     string
+		overTLS  bool
+		wantCode ErrCode
+		locked   bool
+	}{
+		{name: "plain http", pass: testKeystorePass, overTLS: false, wantCode: IllegalAccess, locked: true},
+		{name: "wrong passphrase", pass: "wrong", overTLS: true, wantCode: AuthError, locked: true},
+		{name: "passphrase", pass: testKeystorePass, overTLS: true, wantCode: 0, locked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := unlockRequest(s, `{"source": "treasury", "passphrase": "`+tt.pass+`"}`, tt.overTLS)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.wantCode {
+				t.Fatalf("code = %d, want %d", code, tt.wantCode)
+			}
+			if locked := s.isLocked("treasury:0"); locked != tt.locked {
+				t.Fatalf("locked = %v, want %v", locked, tt.locked)
+			}
+			_, err = s.GetAccount("treasury:0", 1, OpTransaction)
+			if tt.locked != errors.Is(err, ErrAccountNotFound) {
+				t.Fatalf("GetAccount error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLockDuringSign(t *testing.T) {
+	s := newTestLockService(t, "")
+	state, err := s.keyOf("treasury", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.unlockKey(state, testKeystorePass); err != nil {
+		t.Fatal(err)
+	}
+
+	// looked up before the lock, signed after it
+	ai, err := s.GetAccount("treasury:0", 1, OpTransaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priKey := ai.PriKey
+	if !s.lockKey(state, 0) {
+		t.Fatal("lockKey = false for an unlocked key")
+	}
+	if ai.PriKey != nil || priKey.D.Sign() != 0 {
+		t.Fatal("the private key of a handed out account survived the lock")
+	}
+	if s.signingKey(ai) != nil {
+		t.Fatal("signingKey of a locked account")
+	}
+	if _, err = s.GetAccount("treasury:0", 1, OpTransaction); !errors.Is(err, ErrAccountNotFound) || !s.isLocked("treasury:0") {
+		t.Fatalf("GetAccount of a locked key error = %v", err)
+	}
+
+	// signers racing locks and unlocks get a whole key or none
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				ai, err := s.GetAccount("treasury:0", 1, OpTransaction)
+				if err != nil {
+					continue
+				}
+				if key := s.signingKey(ai); key != nil {
+					if crypto.PubkeyToAddress(key.PublicKey) != ai.Address || key.D.Sign() == 0 {
+						t.Error("signing key does not match its account")
+					}
+					account.ZeroKey(key)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		if err = s.unlockKey(state, testKeystorePass); err != nil {
+			t.Fatal(err)
+		}
+		s.lockKey(state, 0)
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestIdleAutoLock(t *testing.T) {
+	s := newTestLockService(t, "100ms")
+	state, err := s.keyOf("treasury", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.unlockKey(state, testKeystorePass); err != nil {
+		t.Fatal(err)
+	}
+
+	// a key used since the idle deadline stays unlocked
+	if _, err = s.GetAccount("treasury:0", 1, OpTransaction); err != nil {
+		t.Fatal(err)
+	}
+	if s.lockKey(state, time.Now().Add(-time.Minute).UnixNano()) {
+		t.Fatal("a key used since the deadline was locked")
+	}
+
+	deadline := time.Now().Add(3 * autoLockInterval)
+	for !s.isLocked("treasury:0") {
+		if time.Now().After(deadline) {
+			t.Fatal("the idle key was not locked")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, err = s.GetAccount("treasury:0", 1, OpTransaction); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("GetAccount after the idle lock error = %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+package service
+
+import (
+	"evm-signer/base"
+	"evm-signer/pkg/logging"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	SetLogger(logging.GetLogger(base.ServiceName, "test", &logging.LogConfig{Level: "error"}).Sugar())
+	os.Exit(m.Run())
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"evm-signer/service/metrics"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+// context keys the handlers fill for the request metrics
+const (
+	codeKey    = "metrics_code"
+	chainIdKey = "metrics_chain_id"
+	ruleKey    = "metrics_rule"
+)
+
+// Metrics counts every request by endpoint, chain id, result code and matched rule
+func (s *Service) Metrics(ctx *gin.Context) {
+	ctx.Next()
+
+	endpoint := ctx.FullPath()
+	if endpoint == "" {
+		endpoint = "unknown"
+	}
+
+	code := ctx.GetString(codeKey)
+	if code == "" {
+		code = "0"
+		if ctx.Writer.Status() >= 300 {
+			code = strconv.Itoa(ctx.Writer.Status())
+		}
+	}
+
+	// only configured chains are labelled, so clients can't grow the label set
+	chainId := ""
+	if id := ctx.GetInt64(chainIdKey); id > 0 {
+		chainId = "unknown"
+		if s.GetChainConfig(uint64(id)) != nil {
+			chainId = strconv.FormatInt(id, 10)
+		}
+	}
+
+	metrics.IncRequest(endpoint, chainId, code, ctx.GetString(ruleKey))
+}
+
+func setCode(ctx *gin.Context, code ErrCode) {
+	ctx.Set(codeKey, fmt.Sprintf("%d", code))
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"time"
+)
+
+type Config struct {
+	Listen string `mapstructure:"listen"` // eg. 127.0.0.1:9100, empty disables the metrics listener
+}
+
+var (
+	registry = prometheus.NewRegistry()
+
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "signer_requests_total",
+		Help: "Requests by endpoint, chain id, result code and matched rule.",
+	}, []string{"endpoint", "chain_id", "code", "rule"})
+
+	ruleEvaluation = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "signer_rule_evaluation_seconds",
+		Help:    "Time spent matching a request against the rules.",
+		Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+	}, []string{"endpoint"})
+
+	signing = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "signer_signing_seconds",
+		Help:    "Time spent signing a request.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"endpoint"})
+
+	accountsLoaded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "signer_accounts_loaded",
+		Help: "Accounts with a loaded private key.",
+	})
+
+	rulesLoaded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "signer_rules_loaded",
+		Help: "Rules of the active rule set.",
+	})
+
+	rulesVersion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "signer_rules_version_info",
+		Help: "Content hash of the active rule set, the value is always 1.",
+	}, []string{"hash"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests, ruleEvaluation, signing, accountsLoaded, rulesLoaded, rulesVersion,
+	)
+}
+
+// Handler serves the metrics in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func IncRequest(endpoint, chainId, code, rule string) {
+	requests.WithLabelValues(endpoint, chainId, code, rule).Inc()
+}
+
+func ObserveRuleEvaluation(endpoint string, start time.Time) {
+	ruleEvaluation.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+}
+
+func ObserveSigning(endpoint string, start time.Time) {
+	signing.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+}
+
+func SetAccountsLoaded(n int) {
+	accountsLoaded.Set(float64(n))
+}
+
+// SetRules records the size and the content hash of the active rule set
+func SetRules(n int, hash string) {
+	rulesLoaded.Set(float64(n))
+	rulesVersion.Reset()
+	rulesVersion.WithLabelValues(hash).Set(1)
+}
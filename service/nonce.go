@@ -0,0 +1,273 @@
+package service
+
+import (
+	"evm-signer/pkg/jsonfile"
+	"evm-signer/service/rules"
+	sTypes "evm-signer/types"
+	"fmt"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultNonceFile    = "data/nonce.json"
+	defaultNonceHistory = 64
+	defaultNonceMinBump = 10
+)
+
+type NonceConfig struct {
+	Enable     bool   `mapstructure:"enable"`
+	File       string `mapstructure:"file"`
+	MaxGap     uint64 `mapstructure:"max_gap"`     // how far the nonce may run ahead of the next nonce, 0 by default
+	History    uint64 `mapstructure:"history"`     // how many nonces below the highest are kept for replacements
+	MinBump    int64  `mapstructure:"min_bump"`    // percentage the fees must be raised by a replacement
+	AutoAssign bool   `mapstructure:"auto_assign"` // assign the next nonce when the transaction has none
+	Start      uint64 `mapstructure:"start"`       // the first nonce assigned to an account which never signed
+}
+
+// signedTx what is kept of a signed transaction to judge its replacements
+type signedTx struct {
+	To        string `json:"to"`
+	Value     string `json:"value"`
+	Data      string `json:"data"`
+	FeeCap    string `json:"fee_cap"`
+	TipCap    string `json:"tip_cap"`
+	Cancelled bool   `json:"cancelled"`
+}
+
+type nonceState struct {
+	Highest uint64               `json:"highest"`
+	Signed  map[uint64]*signedTx `json:"signed"`
+}
+
+// NonceTracker persists the highest nonce signed per account and chain
+// and applies the replacement policy to reused nonces
+type NonceTracker struct {
+	lock    sync.Mutex
+	config  *NonceConfig
+	states  map[string]*nonceState
+	pending map[string]map[uint64]*signedTx // the nonces reserved by requests being signed
+}
+
+// NonceReservation a nonce held by a request until its transaction is signed
+type NonceReservation struct {
+	key   string
+	nonce uint64
+	tx    *signedTx
+	same  bool // the transaction was signed already, committing it changes nothing
+	done  bool
+}
+
+func NewNonceTracker(config *NonceConfig) (*NonceTracker, error) {
+	if config.File == "" {
+		config.File = defaultNonceFile
+	}
+	if config.History == 0 {
+		config.History = defaultNonceHistory
+	}
+	if config.MinBump <= 0 {
+		config.MinBump = defaultNonceMinBump
+	}
+
+	t := &NonceTracker{
+		config:  config,
+		states:  make(map[string]*nonceState),
+		pending: make(map[string]map[uint64]*signedTx),
+	}
+	if err := jsonfile.Load(config.File, &t.states); err != nil {
+		return nil, fmt.Errorf("load nonce file %s error: %s", config.File, err)
+	}
+	return t, nil
+}
+
+func nonceKey(account string, chainId int64) string {
+	return fmt.Sprintf("%s:%d", strings.ToLower(account), chainId)
+}
+
+// AutoAssign whether the tracker assigns nonces to transactions without one
+func (t *NonceTracker) AutoAssign() bool {
+	return t.config.AutoAssign
+}
+
+// Reserve checks the nonce of the transaction, or assigns the next one when it has none and auto_assign is on,
+// and holds it for the request. The reservation is committed once the transaction is signed, or released
+func (t *NonceTracker) Reserve(account string, chainId int64, tx *sTypes.Transaction) (*NonceReservation, error) {
+	cur, err := newSignedTx(account, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	key := nonceKey(account, chainId)
+	state, pending := t.states[key], t.pending[key]
+	if tx.Nonce == "" && t.config.AutoAssign {
+		tx.Nonce = fmt.Sprintf("%d", t.next(state, pending))
+	}
+	nonceInt, ok := rules.ParseTxNumber(tx.Nonce)
+	if !ok || !nonceInt.IsUint64() {
+		return nil, fmt.Errorf("invalid nonce [ %s ]", tx.Nonce)
+	}
+	nonce := nonceInt.Uint64()
+
+	r := &NonceReservation{key: key, nonce: nonce, tx: cur}
+	if _, ok = pending[nonce]; ok {
+		return nil, fmt.Errorf("nonce [ %d ] is being signed by another request", nonce)
+	}
+	if state == nil {
+		// the first nonce of an account is bounded by the start nonce like the following ones
+		if next := t.next(nil, pending); nonce > next+t.config.MaxGap {
+			return nil, fmt.Errorf("nonce [ %d ] is too far ahead, next nonce is [ %d ], max gap is [ %d ]",
+				nonce, next, t.config.MaxGap)
+		} else if nonce < t.config.Start {
+			return nil, fmt.Errorf("nonce [ %d ] is below the start nonce [ %d ]", nonce, t.config.Start)
+		}
+	} else if prev, ok := state.Signed[nonce]; ok {
+		if *prev == *cur {
+			r.same = true
+		} else if err = t.checkReplacement(prev, cur); err != nil {
+			return nil, fmt.Errorf("nonce [ %d ] was already signed: %s", nonce, err)
+		}
+	} else if state.Highest > t.config.History && nonce < state.Highest-t.config.History {
+		return nil, fmt.Errorf("nonce [ %d ] is too old, highest signed nonce is [ %d ]", nonce, state.Highest)
+	} else if next := t.next(state, pending); nonce > next+t.config.MaxGap {
+		return nil, fmt.Errorf("nonce [ %d ] is too far ahead, highest signed or reserved nonce is [ %d ], max gap is [ %d ]",
+			nonce, next-1, t.config.MaxGap)
+	}
+
+	if pending == nil {
+		pending = make(map[uint64]*signedTx)
+		t.pending[key] = pending
+	}
+	pending[nonce] = cur
+	return r, nil
+}
+
+// next the nonce following the highest signed or reserved one, the start nonce for an account without any.
+// The caller holds the lock
+func (t *NonceTracker) next(state *nonceState, pending map[uint64]*signedTx) uint64 {
+	next := t.config.Start
+	if state != nil && state.Highest+1 > next {
+		next = state.Highest + 1
+	}
+	for n := range pending {
+		if n+1 > next {
+			next = n + 1
+		}
+	}
+	return next
+}
+
+// Commit records the nonce of the signed transaction
+func (t *NonceTracker) Commit(r *NonceReservation) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if r.done {
+		return nil
+	}
+	t.unreserve(r)
+	if r.same {
+		return nil
+	}
+
+	state, ok := t.states[r.key]
+	if !ok {
+		state = &nonceState{Highest: r.nonce, Signed: make(map[uint64]*signedTx)}
+		t.states[r.key] = state
+	}
+	state.Signed[r.nonce] = r.tx
+	if r.nonce > state.Highest {
+		state.Highest = r.nonce
+	}
+	for n := range state.Signed {
+		if state.Highest > t.config.History && n < state.Highest-t.config.History {
+			delete(state.Signed, n)
+		}
+	}
+
+	if err := jsonfile.Save(t.config.File, t.states); err != nil {
+		return fmt.Errorf("save nonce file error: %s", err)
+	}
+	return nil
+}
+
+// Release frees the nonce of a request which wasn't signed, a nil or committed reservation is ignored
+func (t *NonceTracker) Release(r *NonceReservation) {
+	if r == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !r.done {
+		t.unreserve(r)
+	}
+}
+
+// unreserve the caller holds the lock
+func (t *NonceTracker) unreserve(r *NonceReservation) {
+	r.done = true
+	delete(t.pending[r.key], r.nonce)
+	if len(t.pending[r.key]) == 0 {
+		delete(t.pending, r.key)
+	}
+}
+
+// checkReplacement a replacement must raise the fees by MinBump percent and
+// either keep to, value and data of the previous transaction or cancel it
+func (t *NonceTracker) checkReplacement(prev, cur *signedTx) error {
+	if prev.Cancelled && !cur.Cancelled {
+		return fmt.Errorf("the previous transaction was cancelled, only a cancel can replace it")
+	}
+	if !cur.Cancelled && (prev.To != cur.To || prev.Value != cur.Value || prev.Data != cur.Data) {
+		return fmt.Errorf("a replacement must keep to, value and data of the previous transaction or cancel it")
+	}
+	if !isBumped(prev.FeeCap, cur.FeeCap, t.config.MinBump) || !isBumped(prev.TipCap, cur.TipCap, t.config.MinBump) {
+		return fmt.Errorf("a replacement must raise the fees by at least %d%%", t.config.MinBump)
+	}
+	return nil
+}
+
+func isBumped(prev, cur string, minBump int64) bool {
+	prevInt, _ := new(big.Int).SetString(prev, 10)
+	curInt, _ := new(big.Int).SetString(cur, 10)
+	if prevInt == nil || curInt == nil {
+		return false
+	}
+	threshold := new(big.Int).Mul(prevInt, big.NewInt(100+minBump))
+	return new(big.Int).Mul(curInt, big.NewInt(100)).Cmp(threshold) >= 0
+}
+
+func newSignedTx(account string, tx *sTypes.Transaction) (*signedTx, error) {
+	value, ok := rules.ParseTxNumber(tx.Value)
+	if !ok {
+		return nil, fmt.Errorf("invalid value [ %s ]", tx.Value)
+	}
+	feeCap, ok := rules.TxFeeCap(tx)
+	if !ok {
+		return nil, fmt.Errorf("invalid fee cap of [ %s ] transaction", tx.Type)
+	}
+	tipCap := feeCap
+	txType, _ := rules.ParseTxNumber(tx.Type)
+	if txType.Uint64() == types.DynamicFeeTxType {
+		if tipCap, ok = rules.ParseTxNumber(tx.MaxPriorityFeePerGas); !ok {
+			return nil, fmt.Errorf("invalid maxPriorityFeePerGas [ %s ]", tx.MaxPriorityFeePerGas)
+		}
+	}
+
+	data := strings.ToLower(tx.Input)
+	if data == "0x" {
+		data = ""
+	}
+	cur := &signedTx{
+		To:     strings.ToLower(tx.To),
+		Value:  value.String(),
+		Data:   data,
+		FeeCap: feeCap.String(),
+		TipCap: tipCap.String(),
+	}
+	cur.Cancelled = cur.To == strings.ToLower(account) && value.Sign() == 0 && data == ""
+	return cur, nil
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	sTypes "evm-signer/types"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const nonceAccount = "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266"
+
+func newTestNonceTracker(t *testing.T, config *NonceConfig) *NonceTracker {
+	config.File = filepath.Join(t.TempDir(), "nonce.json")
+	tracker, err := NewNonceTracker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tracker
+}
+
+func nonceTx(nonce, gasPrice string) *sTypes.Transaction {
+	return &sTypes.Transaction{
+		Type:     "0",
+		Nonce:    nonce,
+		To:       "0x000000000000000000000000000000000000dead",
+		Value:    "1",
+		GasPrice: gasPrice,
+	}
+}
+
+// sign reserves and commits the nonce like a successful signing
+func sign(t *testing.T, tracker *NonceTracker, tx *sTypes.Transaction) error {
+	t.Helper()
+	r, err := tracker.Reserve(nonceAccount, 1, tx)
+	if err != nil {
+		return err
+	}
+	return tracker.Commit(r)
+}
+
+func TestNoncePolicy(t *testing.T) {
+	tracker := newTestNonceTracker(t, &NonceConfig{History: 2, Start: 5})
+	if err := sign(t, tracker, nonceTx("5", "100")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		tx      *sTypes.Transaction
+		wantErr bool
+	}{
+		{"next nonce", nonceTx("6", "100"), false},
+		{"identical resubmission", nonceTx("6", "100"), false},
+		{"replacement without bump", nonceTx("6", "105"), true},
+		{"replacement with bump", nonceTx("6", "110"), false},
+		{"changed replacement", &sTypes.Transaction{Type: "0", Nonce: "6", To: "0x000000000000000000000000000000000000beef", Value: "1", GasPrice: "200"}, true},
+		{"gap", nonceTx("8", "100"), true},
+		{"too old", nonceTx("3", "100"), true},
+		{"invalid", nonceTx("0xzz", "100"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sign(t, tracker, tt.tx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNonceFreshAccount(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *NonceConfig
+		nonce   string
+		wantErr bool
+	}{
+		{"first nonce", &NonceConfig{}, "0", false},
+		{"large nonce", &NonceConfig{}, "1000000", true},
+		{"within the gap", &NonceConfig{MaxGap: 2}, "2", false},
+		{"beyond the gap", &NonceConfig{MaxGap: 2}, "3", true},
+		{"start nonce", &NonceConfig{Start: 40}, "40", false},
+		{"below the start nonce", &NonceConfig{Start: 40}, "39", true},
+		{"large nonce after start", &NonceConfig{Start: 40}, "1000000", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newTestNonceTracker(t, tt.config)
+			err := sign(t, tracker, nonceTx(tt.nonce, "100"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNonceConcurrentManual(t *testing.T) {
+	tracker := newTestNonceTracker(t, &NonceConfig{})
+	if err := sign(t, tracker, nonceTx("0", "100")); err != nil {
+		t.Fatal(err)
+	}
+
+	// two requests racing for the same nonce, only one holds it
+	var wg sync.WaitGroup
+	results := make(chan *NonceReservation, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r, err := tracker.Reserve(nonceAccount, 1, nonceTx("1", "100")); err == nil {
+				results <- r
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+	var held *NonceReservation
+	for r := range results {
+		if held != nil {
+			t.Fatal("nonce 1 reserved twice")
+		}
+		held = r
+	}
+	if held == nil {
+		t.Fatal("nonce 1 was not reserved")
+	}
+
+	// the next nonce may be signed while nonce 1 is still held
+	next, err := tracker.Reserve(nonceAccount, 1, nonceTx("2", "100"))
+	if err != nil {
+		t.Fatalf("nonce 2 after reserved nonce 1: %s", err)
+	}
+	if _, err = tracker.Reserve(nonceAccount, 1, nonceTx("4", "100")); err == nil {
+		t.Fatal("nonce 4 is beyond the gap")
+	}
+	if err = tracker.Commit(next); err != nil {
+		t.Fatal(err)
+	}
+	if err = tracker.Commit(held); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNonceReleaseOnFailure(t *testing.T) {
+	tracker := newTestNonceTracker(t, &NonceConfig{})
+	r, err := tracker.Reserve(nonceAccount, 1, nonceTx("0", "100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tracker.Reserve(nonceAccount, 1, nonceTx("0", "100")); err == nil {
+		t.Fatal("a reserved nonce must not be reserved twice")
+	}
+	// signing failed
+	tracker.Release(r)
+	if err = sign(t, tracker, nonceTx("0", "100")); err != nil {
+		t.Fatalf("retrying the released nonce: %s", err)
+	}
+	// a committed reservation is not released
+	tracker.Release(r)
+	if err = sign(t, tracker, nonceTx("0", "100")); err != nil {
+		t.Fatalf("identical resubmission: %s", err)
+	}
+}
+
+func TestNonceAutoAssign(t *testing.T) {
+	tracker := newTestNonceTracker(t, &NonceConfig{AutoAssign: true, MaxGap: 0, Start: 3})
+	tx := nonceTx("", "100")
+	if err := sign(t, tracker, tx); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Nonce != "3" {
+		t.Fatalf("first assigned nonce = %s, want the start 3", tx.Nonce)
+	}
+
+	const requests = 20
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	nonces := make(map[string]bool)
+	reservations := make([]*NonceReservation, 0, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tx := nonceTx("", "100")
+			r, err := tracker.Reserve(nonceAccount, 1, tx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if nonces[tx.Nonce] {
+				t.Errorf("nonce %s assigned twice", tx.Nonce)
+			}
+			nonces[tx.Nonce] = true
+			reservations = append(reservations, r)
+		}()
+	}
+	wg.Wait()
+	for _, r := range reservations {
+		if err := tracker.Commit(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(nonces) != requests {
+		t.Fatalf("%d distinct nonces, want %d", len(nonces), requests)
+	}
+}
+
+func TestNonceTrackerPersists(t *testing.T) {
+	config := &NonceConfig{AutoAssign: true, Start: 7}
+	tracker := newTestNonceTracker(t, config)
+	if err := sign(t, tracker, nonceTx("7", "100")); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewNonceTracker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := nonceTx("", "100")
+	if err = sign(t, reloaded, tx); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Nonce != "8" {
+		t.Fatalf("assigned nonce = %s, want 8", tx.Nonce)
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"evm-signer/service/rules"
+	"evm-signer/service/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Service) SetNotifier(notifier *webhook.Dispatcher) {
+	s.notifier = notifier
+	if s.approvals != nil {
+		s.approvals.notifier = notifier
+	}
+}
+
+// notifySigned emits a sign.success event
+func (s *Service) notifySigned(ctx *gin.Context, chainId int64, account string, rule *rules.Rule, extra gin.H) {
+	data := gin.H{
+		"client":      ctx.ClientIP(),
+		"path":        ctx.FullPath(),
+		"chain_id":    chainId,
+		"account":     account,
+		"rule":        rule.Name,
+		"rule_source": rule.Source(),
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	s.notifier.Emit(webhook.SignSuccess, data)
+}
+
+// notifyDenied emits a sign.denied event
+func (s *Service) notifyDenied(ctx *gin.Context, code ErrCode, reason string, chainId int64, account string) {
+	s.notifier.Emit(webhook.SignDenied, gin.H{
+		"client":   ctx.ClientIP(),
+		"path":     ctx.FullPath(),
+		"chain_id": chainId,
+		"account":  account,
+		"code":     code,
+		"reason":   reason,
+	})
+}
+
+// notifyAuthFailed emits an auth.failed event, coalesced per client so a client can't flood the spool
+func (s *Service) notifyAuthFailed(ctx *gin.Context, reason string) {
+	s.notifier.EmitCoalesced(webhook.AuthFailed, ctx.ClientIP(), gin.H{
+		"client": ctx.ClientIP(),
+		"path":   ctx.Request.URL.Path,
+		"reason": reason,
+	})
+}
+
+// approvalEvent the approval request without its payload and result
+func approvalEvent(item *Approval) gin.H {
+	return gin.H{
+		"id":          item.ID,
+		"path":        item.Path,
+		"chain_id":    item.ChainId,
+		"account":     item.Account,
+		"client":      item.Client,
+		"rule":        item.Rule,
+		"rule_source": item.RuleSource,
+		"quorum":      item.Quorum,
+		"approvers":   item.Approvers,
+		"rejecter":    item.Rejecter,
+		"status":      item.Status,
+		"expires_at":  item.ExpiresAt,
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"evm-signer/service/policy"
+	"evm-signer/service/rules"
+	"fmt"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Service) SetPolicy(client *policy.Client) {
+	s.policy = client
+}
+
+// checkPolicy asks the policy decision point about a request the rules allowed,
+// it responds the deny reason and returns false unless the decision is an explicit allow
+func (s *Service) checkPolicy(ctx *gin.Context, chainId int64, account string, matchRule *rules.Rule,
+	kind string, request, call interface{}) bool {
+	if s.policy == nil {
+		return true
+	}
+
+	decision, err := s.policy.Decide(ctx.Request.Context(), &policy.Request{
+		Path:     ctx.FullPath(),
+		Client:   ctx.ClientIP(),
+		ChainId:  chainId,
+		Account:  account,
+		Type:     kind,
+		Request:  request,
+		Call:     call,
+		Rule:     matchRule,
+		Source:   matchRule.Source(),
+		Approved: isApproved(ctx),
+	})
+	if err != nil {
+		_msg := fmt.Sprintf("policy check for [ %s ] account on [ %d ] chain by rule [ %s ] failed: [ %s ]",
+			account, chainId, matchRule.Name, err.Error())
+		logger.Errorf(_msg)
+		s.notifyDenied(ctx, PolicyError, _msg, chainId, account)
+		ReturnError(ctx, PolicyError, _msg)
+		return false
+	}
+	if !decision.Allowed() {
+		_msg := fmt.Sprintf("policy denied [ %s ] account on [ %d ] chain by rule [ %s ]: [ %s ]",
+			account, chainId, matchRule.Name, decision.Reason)
+		logger.Errorf(_msg)
+		s.notifyDenied(ctx, PolicyDenied, _msg, chainId, account)
+		ReturnError(ctx, PolicyDenied, _msg)
+		return false
+	}
+	logger.Infof("policy allowed [ %s ] account on [ %d ] chain by rule [ %s ]", account, chainId, matchRule.Name)
+	return true
+}
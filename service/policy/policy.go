@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	Allow = "allow"
+	Deny  = "deny"
+
+	defaultTimeout = 2 * time.Second
+	maxResponse    = 64 * 1024
+)
+
+type Config struct {
+	URL     string            `mapstructure:"url"`     // the decision endpoint, empty disables the callout
+	Timeout string            `mapstructure:"timeout"` // default 2s
+	Headers map[string]string `mapstructure:"headers"` // sent with every callout, eg. an auth token
+}
+
+// Request what the decision point gets after the local rules matched
+type Request struct {
+	ID       string      `json:"id"`
+	Time     time.Time   `json:"time"`
+	Path     string      `json:"path"`
+	Client   string      `json:"client"`
+	ChainId  int64       `json:"chain_id"`
+	Account  string      `json:"account"`
+	Type     string      `json:"type"` // transaction, eip712 or message
+	Request  interface{} `json:"request"`
+	Call     interface{} `json:"call,omitempty"` // the calldata decoded by the rule abi
+	Rule     interface{} `json:"rule"`
+	Source   string      `json:"rule_source"` // the rule file and line
+	Approved bool        `json:"approved"`    // the request was approved and is being signed
+}
+
+// Decision the answer of the decision point, only decision allow signs
+type Decision struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+}
+
+func (d *Decision) Allowed() bool {
+	return d.Decision == Allow
+}
+
+// Client posts requests to the decision point, a nil client allows everything
+type Client struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// New the client of the config, nil when no url is configured
+func New(config *Config) (*Client, error) {
+	if config == nil || config.URL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid policy url %s", config.URL)
+	}
+	timeout := defaultTimeout
+	if config.Timeout != "" {
+		timeout, err = time.ParseDuration(config.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid policy timeout %s", config.Timeout)
+		}
+	}
+	return &Client{
+		url:     config.URL,
+		headers: config.Headers,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (c *Client) URL() string {
+	return c.url
+}
+
+// Decide posts the request and returns the decision, any error means the request must be denied
+func (c *Client) Decide(ctx context.Context, request *Request) (*Decision, error) {
+	if request.ID == "" {
+		request.ID = uuid.NewString()
+	}
+	if request.Time.IsZero() {
+		request.Time = time.Now().UTC()
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+
+	decision := new(Decision)
+	if err = json.Unmarshal(data, decision); err != nil {
+		return nil, fmt.Errorf("invalid decision %s: %s", bytes.TrimSpace(data), err)
+	}
+	if decision.Decision != Allow && decision.Decision != Deny {
+		return nil, fmt.Errorf("unknown decision %q", decision.Decision)
+	}
+	return decision, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"evm-signer/service/rules"
+	"evm-signer/service/webhook"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RateLimitConfig struct {
+	Client  *rules.RateLimit `mapstructure:"client"`  // per client identity
+	Account *rules.RateLimit `mapstructure:"account"` // per signing account
+}
+
+// RateLimiter keeps a token bucket for every client, account and rule key
+type RateLimiter struct {
+	lock     sync.Mutex
+	config   *RateLimitConfig
+	limiters map[string]*rate.Limiter
+}
+
+func NewRateLimiter(config *RateLimitConfig) (*RateLimiter, error) {
+	for name, limit := range map[string]*rules.RateLimit{"client": config.Client, "account": config.Account} {
+		if limit == nil {
+			continue
+		}
+		if _, _, err := limit.Init(); err != nil {
+			return nil, fmt.Errorf("%s rate limit error: %s", name, err)
+		}
+	}
+	return &RateLimiter{
+		config:   config,
+		limiters: make(map[string]*rate.Limiter),
+	}, nil
+}
+
+// Reserve takes a token from the bucket of the key,
+// it returns how long to wait when the bucket is empty
+func (l *RateLimiter) Reserve(key string, limit *rules.RateLimit) (time.Duration, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	limiter, ok := l.limiters[key]
+	if !ok {
+		every, burst, err := limit.Init()
+		if err != nil {
+			return 0, err
+		}
+		limiter = rate.NewLimiter(every, burst)
+		l.limiters[key] = limiter
+	}
+
+	now := time.Now()
+	reservation := limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return 0, fmt.Errorf("rate limit burst is 0")
+	}
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		reservation.CancelAt(now)
+	}
+	return delay, nil
+}
+
+// limitClient applies the client rate limit, it writes the error response when the limit is hit.
+// An approved request replayed was counted when it was parked
+func (s *Service) limitClient(ctx *gin.Context) bool {
+	if s.limiter == nil || s.limiter.config.Client == nil || isApproved(ctx) {
+		return true
+	}
+	return s.limit(ctx, "client:"+ctx.ClientIP(), s.limiter.config.Client)
+}
+
+// limitAccount applies the account rate limit
+func (s *Service) limitAccount(ctx *gin.Context, account string) bool {
+	if s.limiter == nil || s.limiter.config.Account == nil {
+		return true
+	}
+	return s.limit(ctx, "account:"+strings.ToLower(account), s.limiter.config.Account)
+}
+
+// limitRule applies the rate limit of the matched rule, per client identity
+func (s *Service) limitRule(ctx *gin.Context, rule *rules.Rule) bool {
+	if s.limiter == nil || rule.RateLimit == nil {
+		return true
+	}
+	return s.limit(ctx, "rule:"+rule.Name+":"+ctx.ClientIP(), rule.RateLimit)
+}
+
+func (s *Service) limit(ctx *gin.Context, key string, limit *rules.RateLimit) bool {
+	delay, err := s.limiter.Reserve(key, limit)
+	if err != nil {
+		_msg := fmt.Sprintf("[ %s ] rate limit error: [ %s ]", key, err.Error())
+		logger.Errorf(_msg)
+		ReturnError(ctx, InternalError, _msg)
+		return false
+	}
+	if delay > 0 {
+		_msg := fmt.Sprintf("[ %s ] rate limit exceeded, retry after [ %s ]", key, delay)
+		logger.Warnf(_msg)
+		s.notifier.Emit(webhook.SignDenied, gin.H{
+			"client": ctx.ClientIP(),
+			"path":   ctx.FullPath(),
+			"code":   RateLimited,
+			"reason": _msg,
+		})
+		ReturnRateLimited(ctx, delay, _msg)
+		return false
+	}
+	return true
+}
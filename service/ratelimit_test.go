@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"evm-signer/service/rules"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func rateLimitContext(ip string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/sign", nil)
+	ctx.Request.RemoteAddr = ip + ":1234"
+	return ctx, w
+}
+
+func newTestLimitService(t *testing.T, config *RateLimitConfig) *Service {
+	limiter, err := NewRateLimiter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Service{limiter: limiter}
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	s := newTestLimitService(t, &RateLimitConfig{Client: &rules.RateLimit{Limit: 1, Period: "1m", Burst: 3}})
+	for i := 0; i < 3; i++ {
+		ctx, _ := rateLimitContext("10.0.0.1")
+		if !s.limitClient(ctx) {
+			t.Fatalf("request %d within the burst was limited", i+1)
+		}
+	}
+
+	ctx, w := rateLimitContext("10.0.0.1")
+	if s.limitClient(ctx) {
+		t.Fatal("request over the burst was allowed")
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter <= 0 || retryAfter > 60 {
+		t.Fatalf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+	var resp struct {
+		Code ErrCode `json:"code"`
+		Data struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"data"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != RateLimited || resp.Data.RetryAfter != retryAfter {
+		t.Fatalf("response = %+v, want code %d and retry_after %d", resp, RateLimited, retryAfter)
+	}
+
+	// another client has its own bucket
+	ctx, _ = rateLimitContext("10.0.0.2")
+	if !s.limitClient(ctx) {
+		t.Fatal("another client was limited")
+	}
+}
+
+func TestRateLimitRule(t *testing.T) {
+	s := newTestLimitService(t, &RateLimitConfig{})
+	transfer := &rules.Rule{Name: "transfer", RateLimit: &rules.RateLimit{Limit: 1, Period: "1m"}}
+	approve := &rules.Rule{Name: "approve", RateLimit: &rules.RateLimit{Limit: 1, Period: "1m"}}
+
+	tests := []struct {
+		name    string
+		ip      string
+		rule    *rules.Rule
+		allowed bool
+	}{
+		{"first request", "10.0.0.1", transfer, true},
+		{"same rule and client", "10.0.0.1", transfer, false},
+		{"same rule, other client", "10.0.0.2", transfer, true},
+		{"other rule, same client", "10.0.0.1", approve, true},
+		{"rule without limit", "10.0.0.1", &rules.Rule{Name: "free"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := rateLimitContext(tt.ip)
+			if allowed := s.limitRule(ctx, tt.rule); allowed != tt.allowed {
+				t.Fatalf("allowed = %v, want %v", allowed, tt.allowed)
+			}
+		})
+	}
+
+	for _, key := range []string{"rule:transfer:10.0.0.1", "rule:transfer:10.0.0.2", "rule:approve:10.0.0.1"} {
+		if _, ok := s.limiter.limiters[key]; !ok {
+			t.Errorf("no bucket for %s", key)
+		}
+	}
+	if len(s.limiter.limiters) != 3 {
+		t.Errorf("%d buckets, want 3", len(s.limiter.limiters))
+	}
+}
+
+func TestRateLimitApprovedReplay(t *testing.T) {
+	s := newTestLimitService(t, &RateLimitConfig{Client: &rules.RateLimit{Limit: 1, Period: "1m"}})
+	ctx, _ := rateLimitContext("10.0.0.1")
+	if !s.limitClient(ctx) {
+		t.Fatal("first request was limited")
+	}
+
+	// the replay of an approved request was counted when it was parked
+	ctx, _ = rateLimitContext("10.0.0.1")
+	ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), approvedKey{}, "id"))
+	if !s.limitClient(ctx) {
+		t.Fatal("approved replay was limited")
+	}
+	ctx, _ = rateLimitContext("10.0.0.1")
+	if s.limitClient(ctx) {
+		t.Fatal("request over the limit was allowed")
+	}
+}
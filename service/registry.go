@@ -0,0 +1,253 @@
+package service
+
+import (
+	"evm-signer/service/account"
+	"evm-signer/service/metrics"
+	"evm-signer/types"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// accountRef the source:index reference of an account, lower case like every reference
+func accountRef(name string, index int64) string {
+	return fmt.Sprintf("%s:%d", strings.ToLower(name), index)
+}
+
+// parseRef the source and the index of a source:index reference or of an alias
+func (k *keyring) parseRef(ref string) (string, int64, bool) {
+	ref = strings.ToLower(ref)
+	if _ref, ok := k.aliases[ref]; ok {
+		ref = _ref
+	}
+	sep := strings.LastIndex(ref, ":")
+	if sep < 0 {
+		return "", 0, false
+	}
+	index, err := strconv.ParseInt(ref[sep+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return ref[:sep], index, true
+}
+
+// loadAccounts rebuilds the account maps from the keyring, a locked key keeps its known addresses without
+// a private key and a lazy mnemonic the accounts derived so far. An address of two sources belongs to the
+// first one loaded. The caller holds the service lock
+func (s *Service) loadAccounts() {
+	s.accountsForAddr = make(map[string]*types.Account)
+	s.accountForRef = make(map[string]*types.Account)
+	s.keyring.keyForRef = make(map[string]*keyState)
+	s.loadedAccounts = 0
+
+	for _, _account := range s.keyring.plain {
+		s.addAccount(_account, nil)
+	}
+	for _, wallet := range s.keyring.wallets {
+		for _, _account := range wallet.Cached() {
+			s.addAccount(_account, nil)
+		}
+	}
+	for _, state := range s.keyring.keys {
+		accounts := state.accounts
+		if state.wallet != nil {
+			accounts = state.wallet.Cached()
+		}
+		if state.locked() {
+			accounts = nil
+			for _, address := range state.key.Addresses {
+				accounts = append(accounts, &types.Account{
+					Index:   state.key.Index,
+					Address: address,
+					Source:  string(state.key.Type),
+					Name:    state.key.Name,
+				})
+			}
+		}
+		for _, _account := range accounts {
+			s.addAccount(_account, state)
+		}
+	}
+	metrics.SetAccountsLoaded(s.loadedAccounts)
+}
+
+// addAccount registers the account by its address, source:index and aliases. The caller holds the service lock
+func (s *Service) addAccount(_account *types.Account, state *keyState) {
+	address := strings.ToLower(_account.Address.Hex())
+	if other, ok := s.accountsForAddr[address]; ok {
+		logger.Warnf("[Account] [ %s ] of [ %s ] is already loaded by [ %s ], ignored",
+			_account.Address.Hex(), _account.Name, other.Name)
+		return
+	}
+	s.accountsForAddr[address] = _account
+	if s.hasKey(_account) {
+		s.loadedAccounts++
+	}
+
+	ref := accountRef(_account.Name, _account.Index)
+	refs := append([]string{address, ref}, s.keyring.refAliases[ref]...)
+	for _, _ref := range refs {
+		s.accountForRef[_ref] = _account
+		if state != nil {
+			s.keyring.keyForRef[_ref] = state
+		}
+	}
+}
+
+// hasKey whether the account can sign, its private key is loaded or it is an account of an unlocked lazy
+// mnemonic, which derives its key for every signing. The caller holds the service lock
+func (s *Service) hasKey(_account *types.Account) bool {
+	if _account.PriKey != nil {
+		return true
+	}
+	return _account.PubKey != nil && s.keyring != nil && s.keyring.walletOf(accountRef(_account.Name, _account.Index)) != nil
+}
+
+// lookup the account of a reference, an index of a lazy mnemonic is derived by its first lookup
+func (s *Service) lookup(ref string) (*types.Account, bool) {
+	s.lock.RLock()
+	_account, ok := s.accountForRef[strings.ToLower(ref)]
+	s.lock.RUnlock()
+	if ok {
+		return _account, true
+	}
+	return s.derive(ref, false)
+}
+
+// derive the account of a lazy mnemonic a source:index or an alias names, false when it names none.
+// Only an alias, an allocated index or keep registers the account, any other index is derived again by every
+// lookup, so clients looking up arbitrary indices don't grow the account maps
+func (s *Service) derive(ref string, keep bool) (*types.Account, bool) {
+	s.lock.RLock()
+	if s.keyring == nil {
+		s.lock.RUnlock()
+		return nil, false
+	}
+	lazy := s.keyring.walletOf(ref)
+	if lazy != nil && len(s.keyring.refAliases[accountRef(lazy.wallet.Name, lazy.index)]) != 0 {
+		keep = true
+	}
+	s.lock.RUnlock()
+	if lazy == nil {
+		return nil, false
+	}
+	if !keep && s.allocator != nil {
+		keep = lazy.index < s.allocator.next(lazy.wallet.Name)
+	}
+
+	// derived without the service lock, the wallet caches the address of a kept account
+	derive := lazy.wallet.Derive
+	if keep {
+		derive = lazy.wallet.Account
+	}
+	_account, err := derive(lazy.index)
+	if err != nil {
+		logger.Errorf("[Account] derive [ %s ] error: %s", ref, err)
+		return nil, false
+	}
+
+	if !keep {
+		s.lock.RLock()
+		defer s.lock.RUnlock()
+		if lazy.state != nil && lazy.state.wallet != lazy.wallet {
+			// locked meanwhile
+			return nil, false
+		}
+		return _account, true
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if lazy.state != nil && lazy.state.wallet != lazy.wallet {
+		// locked meanwhile
+		return nil, false
+	}
+	key := accountRef(_account.Name, _account.Index)
+	if _, ok := s.accountForRef[key]; !ok {
+		s.addAccount(_account, lazy.state)
+		metrics.SetAccountsLoaded(s.loadedAccounts)
+	}
+	_account, ok := s.accountForRef[key]
+	return _account, ok
+}
+
+// lazyRef an index of a lazy mnemonic, the state is nil for a PlainMnemonic
+type lazyRef struct {
+	wallet *account.HDWallet
+	state  *keyState
+	index  int64
+}
+
+// walletOf the unlocked lazy mnemonic of a source:index or an alias within its index range.
+// The caller holds the service lock
+func (k *keyring) walletOf(ref string) *lazyRef {
+	name, index, ok := k.parseRef(ref)
+	if !ok {
+		return nil
+	}
+	for _, wallet := range k.wallets {
+		if strings.ToLower(wallet.Name) == name && wallet.Ranges().Contains(index) {
+			return &lazyRef{wallet: wallet, index: index}
+		}
+	}
+	for _, state := range k.keys {
+		if state.wallet != nil && strings.ToLower(state.wallet.Name) == name && state.wallet.Ranges().Contains(index) {
+			return &lazyRef{wallet: state.wallet, state: state, index: index}
+		}
+	}
+	return nil
+}
+
+// preload derives the addresses of the indices of a lazy mnemonic handed out as deposit addresses already, so
+// deposits are found by their address. Their private keys are not kept
+func (s *Service) preload(wallet *account.HDWallet, state *keyState) {
+	if s.allocator == nil {
+		return
+	}
+	next := s.allocator.next(wallet.Name)
+	count := 0
+	for index, ok := wallet.Ranges().Next(0); ok && index < next; index, ok = wallet.Ranges().Next(index + 1) {
+		if _, err := wallet.Account(index); err != nil {
+			logger.Errorf("[Account] derive [ %s ] error: %s", accountRef(wallet.Name, index), err)
+			continue
+		}
+		count++
+	}
+	if count == 0 {
+		return
+	}
+
+	s.lock.Lock()
+	if state == nil || state.wallet == wallet {
+		s.loadAccounts()
+	}
+	s.lock.Unlock()
+	logger.Infof("[Account] derived [ %d ] allocated accounts of [ %s ]", count, wallet.Name)
+}
+
+// restrictionOf the restriction of an account, nil when it may sign anything
+func (k *keyring) restrictionOf(_account *types.Account) *account.Restriction {
+	if restriction, ok := k.restricts[accountRef(_account.Name, _account.Index)]; ok {
+		return restriction
+	}
+	return k.restricts[strings.ToLower(_account.Name)]
+}
+
+// keyOfRef the key of an account reference, also the locked key of an encrypted mnemonic whose addresses
+// are not known yet. The caller holds the service lock
+func (k *keyring) keyOfRef(ref string) *keyState {
+	if state, ok := k.keyForRef[strings.ToLower(ref)]; ok {
+		return state
+	}
+	name, index, ok := k.parseRef(ref)
+	if !ok {
+		return nil
+	}
+	for _, state := range k.keys {
+		key := state.key
+		if strings.ToLower(key.Name) == name && (key.Root() && key.Type == account.EncryptedMnemonicTy || key.Index == index) {
+			return state
+		}
+	}
+	return nil
+}
@@ -7,10 +7,30 @@ import (
 
 func (s *Service) GetRouter() http.Handler {
 	router := gin.Default()
+	router.Use(s.Metrics)
 	router.GET("/ping", s.Pong)
 	router.POST("/v1/sign/transaction", s.GetSign)
 	router.POST("/v1/sign/eip712", s.GetSign712)
 	router.POST("/v1/sign/message", s.GetSignMessage)
 	router.POST("/v1/address", s.GetAddress)
+	router.POST("/v1/address/allocate", s.AllocateAddress)
+	router.GET("/v1/addresses", s.ListAddresses)
+	router.GET("/v1/addresses/xpubs", s.ListXPubs)
+	router.GET("/v1/sign/result/:id", s.GetApprovalResult)
+
+	admin := router.Group("/admin/v1", s.AdminAuth)
+	admin.GET("/status", s.Status)
+	admin.GET("/accounts", s.ListAccounts)
+	admin.GET("/keys", s.ListKeys)
+	admin.POST("/unlock", s.Unlock)
+	admin.POST("/lock", s.Lock)
+	admin.GET("/allocations", s.ListAllocations)
+	admin.GET("/chains", s.ListChains)
+	admin.GET("/rules", s.ListRules)
+	admin.GET("/approvals", s.ListApprovals)
+	admin.POST("/approvals/:id/approve", s.Approve)
+	admin.POST("/approvals/:id/reject", s.Reject)
+
+	s.router = router
 	return router
 }
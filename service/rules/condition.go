@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/shopspring/decimal"
 	"math/big"
@@ -22,6 +23,15 @@ const (
 	DataSelectorField             Field = "data_selector"
 	DataField                     Field = "data"
 	DataParamField                Field = "data_param"
+	GasField                      Field = "gas"
+	GasPriceField                 Field = "gas_price"
+	MaxFeePerGasField             Field = "max_fee_per_gas"
+	MaxPriorityFeePerGasField     Field = "max_priority_fee_per_gas"
+	FeeField                      Field = "fee"        // worst-case fee: gas * (maxFeePerGas or gasPrice)
+	TotalCostField                Field = "total_cost" // fee + value
+	TxTypeField                   Field = "tx_type"
+	NonceField                    Field = "nonce"
+	AccessListField               Field = "access_list"
 	MessageField                  Field = "message"
 	Eip712DomainName              Field = "eip712.domain.name"
 	Eip712DomainVersion           Field = "eip712.domain.version"
@@ -93,11 +103,19 @@ type Condition struct {
 	Param    string `json:"param"` // 自定义ABI的比较参数名
 	inputs   abi.Arguments
 	selector string
+
+	// the value pre-parsed by Init, so matching never re-parses it
+	compiled bool
+	values   []string   // the in list
+	number   *big.Int   // nil when the value is not a decimal number
+	numbers  []*big.Int // the decimal numbers of the in list
+	regex    *regexp.Regexp
 }
 
 func (c *Condition) Init() {
 	// lowerCase
 	c.Value = strings.ToLower(c.Value)
+	c.compile()
 	// init abi
 	if c.Abi == "" {
 		return
@@ -119,6 +137,35 @@ func (c *Condition) Init() {
 	c.inputs = _abi.Methods[funcName].Inputs
 }
 
+// compile pre-parses the value for every symbol it may be compared with
+func (c *Condition) compile() {
+	c.values = strings.Split(c.Value, ",")
+	c.number, _ = new(big.Int).SetString(c.Value, 10)
+	c.numbers = nil
+	for _, v := range c.values {
+		if number, ok := new(big.Int).SetString(strings.TrimSpace(v), 10); ok {
+			c.numbers = append(c.numbers, number)
+		}
+	}
+	c.regex = nil
+	if c.Symbol == RegexSymbol {
+		regex, err := regexp.Compile(c.Value)
+		if err != nil {
+			logger.Warnf("[%s] condition value is not a valid regex: %s", c.Value, err)
+		}
+		c.regex = regex
+	}
+	c.compiled = true
+}
+
+// inValues the in list of the value
+func (c *Condition) inValues() []string {
+	if c.compiled {
+		return c.values
+	}
+	return strings.Split(c.Value, ",")
+}
+
 func (c *Condition) IsMatch712(msg712 *apitypes.TypedData) bool {
 	isMatch := false
 	switch c.Field {
@@ -242,15 +289,95 @@ func (c *Condition) IsMatch(tx *types.Transaction) bool {
 		}
 		return c.IsMatchString(tx.Input[0:10], c.Symbol)
 	case DataField:
-		c.Value = strings.ToLower(c.Value)
 		return c.IsMatchString(strings.ToLower(tx.Input), c.Symbol)
 	case DataParamField:
 		return c.isMatchDataParam(tx.Input)
+	case GasField:
+		return c.isMatchTxNumber(c.Field, tx.Gas)
+	case GasPriceField:
+		return c.isMatchTxNumber(c.Field, tx.GasPrice)
+	case MaxFeePerGasField:
+		return c.isMatchTxNumber(c.Field, tx.MaxFeePerGas)
+	case MaxPriorityFeePerGasField:
+		return c.isMatchTxNumber(c.Field, tx.MaxPriorityFeePerGas)
+	case TxTypeField:
+		return c.isMatchTxNumber(c.Field, tx.Type)
+	case NonceField:
+		return c.isMatchTxNumber(c.Field, tx.Nonce)
+	case FeeField:
+		fee, ok := TxFee(tx)
+		if !ok {
+			logger.Warnf("[FeeField] can not compute fee for gas: %s", tx.Gas)
+			return false
+		}
+		return c.IsMatchBigInt(fee, c.Symbol)
+	case TotalCostField:
+		cost, ok := TxCost(tx)
+		if !ok {
+			logger.Warnf("[TotalCostField] can not compute cost for gas: %s, value: %s", tx.Gas, tx.Value)
+			return false
+		}
+		return c.IsMatchBigInt(cost, c.Symbol)
+	case AccessListField:
+		return c.isMatchAccessList(tx.AccessList)
 	default:
 		return false
 	}
 }
 
+func (c *Condition) isMatchTxNumber(field Field, str string) bool {
+	value, ok := ParseTxNumber(str)
+	if !ok {
+		logger.Warnf("[%s] tx field can not convert to big.int: %s", field, str)
+		return false
+	}
+	return c.IsMatchBigInt(value, c.Symbol)
+}
+
+// isMatchAccessList compares the addresses of the access list with the condition value, the storage keys
+// of the entries are not compared:
+// == the access list addresses equal the value list ("" means an empty access list)
+// in every access list address is in the value list
+// contains the access list includes the value address
+func (c *Condition) isMatchAccessList(accessList ethTypes.AccessList) bool {
+	addresses := make([]string, 0, len(accessList))
+	for _, tuple := range accessList {
+		addresses = append(addresses, strings.ToLower(tuple.Address.Hex()))
+	}
+
+	switch c.Symbol {
+	case EqualSymbol:
+		var _values []string
+		if c.Value != "" {
+			_values = c.inValues()
+		}
+		for _, address := range addresses {
+			if !IsContains(_values, address) {
+				return false
+			}
+		}
+		for _, _value := range _values {
+			if !IsContains(addresses, _value) {
+				return false
+			}
+		}
+		return true
+	case InSymbol:
+		_values := c.inValues()
+		for _, address := range addresses {
+			if !IsContains(_values, address) {
+				return false
+			}
+		}
+		return true
+	case ContainsSymbol:
+		return IsContains(addresses, c.Value)
+	default:
+		logger.Warnf("unsupported symbol %s for access_list", c.Symbol)
+		return false
+	}
+}
+
 func (c *Condition) isMatchDataParam(input string) bool {
 	if c.inputs == nil || len(c.inputs) == 0 {
 		logger.Warnf("[DataParamField] abi not initialized, check abi field in condition")
@@ -301,23 +428,38 @@ func (c *Condition) IsMatchString(value string, symbol Symbol) bool {
 	case EqualSymbol:
 		return strings.EqualFold(value, c.Value)
 	case InSymbol:
-		_values := strings.Split(c.Value, ",")
-		return IsContains(_values, value)
+		return IsContains(c.inValues(), value)
 	case ContainsSymbol:
 		return strings.Contains(value, c.Value)
 	case RegexSymbol:
+		if c.compiled {
+			return c.regex != nil && c.regex.MatchString(value)
+		}
 		matched, err := regexp.MatchString(c.Value, value)
 		if err != nil {
 			return false
 		}
 		return matched
 	default:
-		logger.Warnf("unpported symbol %s", symbol)
+		logger.Warnf("unsupported symbol %s", symbol)
 		return false
 	}
 }
 
 func (c *Condition) IsMatchBigInt(value *big.Int, symbol Symbol) bool {
+	if c.compiled {
+		return c.isMatchCompiledBigInt(value, symbol)
+	}
+	if symbol == InSymbol {
+		for _, v := range strings.Split(c.Value, ",") {
+			_value, match := new(big.Int).SetString(strings.TrimSpace(v), 10)
+			if match && value.Cmp(_value) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
 	_value, match := new(big.Int).SetString(c.Value, 10)
 	if !match {
 		logger.Warnf("[%s] condition value can not convent from string to big.int", c.Value)
@@ -335,6 +477,32 @@ func (c *Condition) IsMatchBigInt(value *big.Int, symbol Symbol) bool {
 	}
 }
 
+func (c *Condition) isMatchCompiledBigInt(value *big.Int, symbol Symbol) bool {
+	if symbol == InSymbol {
+		for _, number := range c.numbers {
+			if value.Cmp(number) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	if c.number == nil {
+		logger.Warnf("[%s] condition value can not convent from string to big.int", c.Value)
+		return false
+	}
+	switch symbol {
+	case EqualSymbol:
+		return value.Cmp(c.number) == 0
+	case GrateAndEqualSymbol:
+		return value.Cmp(c.number) >= 0
+	case LessAndEqualSymbol:
+		return value.Cmp(c.number) <= 0
+	default:
+		return false
+	}
+}
+
 func (c *Condition) IsMatchBool(value bool, symbol Symbol) bool {
 	switch symbol {
 	case EqualSymbol:
@@ -0,0 +1,123 @@
+package rules
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"evm-signer/types"
+	"fmt"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"io"
+	"strings"
+)
+
+const (
+	ExpectAllow = "allow"
+	ExpectDeny  = "deny"
+)
+
+// Fixture a request with its expected outcome, one JSON object per line of a rule test file
+type Fixture struct {
+	Name        string          `json:"name"`
+	ChainId     int64           `json:"chain_id"`
+	Account     string          `json:"account"`     // the signing account, it is the from of a transaction
+	Transaction json.RawMessage `json:"transaction"` // object or JSON string as in the sign api
+	Eip712      json.RawMessage `json:"eip712"`      // typed data, object or JSON string
+	Message     *string         `json:"message"`
+	Expect      string          `json:"expect"` // allow or deny
+	Rule        string          `json:"rule"`   // the rule expected to allow the request, optional
+
+	Line int `json:"-"`
+}
+
+// ReadFixtures reads a JSONL fixture file, blank lines and lines starting with # are skipped
+func ReadFixtures(r io.Reader) ([]*Fixture, error) {
+	var fixtures []*Fixture
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+		fixture := &Fixture{Line: line}
+		if err := dec.Decode(fixture); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		if err := fixture.check(); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		if fixture.Name == "" {
+			fixture.Name = fmt.Sprintf("line %d", line)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, scanner.Err()
+}
+
+func (f *Fixture) check() error {
+	requests := 0
+	for _, set := range []bool{len(f.Transaction) > 0, len(f.Eip712) > 0, f.Message != nil} {
+		if set {
+			requests++
+		}
+	}
+	if requests != 1 {
+		return fmt.Errorf("exactly one of transaction, eip712 or message is required")
+	}
+	if f.Expect != ExpectAllow && f.Expect != ExpectDeny {
+		return fmt.Errorf("expect must be %s or %s, got %q", ExpectAllow, ExpectDeny, f.Expect)
+	}
+	if f.Expect == ExpectDeny && f.Rule != "" {
+		return fmt.Errorf("rule is only expected for allow")
+	}
+	return nil
+}
+
+// Match runs the request through the rules like the sign handlers do,
+// the parsed transaction is returned for the checks after the rule match
+func (f *Fixture) Match(rs RuleMatcher) (*Rule, *types.Transaction, error) {
+	match, tx, err := f.Prepare()
+	if err != nil {
+		return nil, nil, err
+	}
+	return match(rs), tx, nil
+}
+
+// Prepare parses the request once, the returned func matches it against rules
+func (f *Fixture) Prepare() (func(rs RuleMatcher) *Rule, *types.Transaction, error) {
+	switch {
+	case len(f.Transaction) > 0:
+		tx := new(types.Transaction)
+		if err := unmarshalEmbedded(f.Transaction, tx); err != nil {
+			return nil, nil, fmt.Errorf("invalid transaction: %s", err)
+		}
+		if f.Account != "" {
+			tx.From = strings.ToLower(f.Account)
+		}
+		return func(rs RuleMatcher) *Rule { return rs.GetMatched(f.ChainId, tx) }, tx, nil
+	case len(f.Eip712) > 0:
+		typedData := new(apitypes.TypedData)
+		if err := unmarshalEmbedded(f.Eip712, typedData); err != nil {
+			return nil, nil, fmt.Errorf("invalid eip712 data: %s", err)
+		}
+		return func(rs RuleMatcher) *Rule { return rs.GetMatchedEip712(f.ChainId, typedData) }, nil, nil
+	default:
+		message := *f.Message
+		return func(rs RuleMatcher) *Rule { return rs.GetMatchedMessage(f.ChainId, message) }, nil, nil
+	}
+}
+
+// unmarshalEmbedded decodes an object, or a JSON string holding the object
+func unmarshalEmbedded(data json.RawMessage, v interface{}) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+		data = json.RawMessage(str)
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,456 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Level string
+
+const (
+	LevelError Level = "ERROR"
+	LevelWarn  Level = "WARN"
+	LevelInfo  Level = "INFO"
+)
+
+// Problem a finding of the rule linter
+type Problem struct {
+	File   string
+	Line   int
+	Column int
+	Rule   int // index of the rule in the file
+	Name   string
+	Path   string // path in the rule, eg. conditions[1].value
+	Level  Level
+	Msg    string
+}
+
+func (p *Problem) String() string {
+	location := p.File
+	if p.Line > 0 {
+		location = fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+	if p.Rule < 0 {
+		return fmt.Sprintf("%s: %-5s %s", location, p.Level, p.Msg)
+	}
+	target := fmt.Sprintf("rule[%d] %s", p.Rule, p.Name)
+	if p.Path != "" {
+		target += " " + p.Path
+	}
+	return fmt.Sprintf("%s: %-5s %s: %s", location, p.Level, target, p.Msg)
+}
+
+// HasErrors reports whether any problem is an error
+func HasErrors(problems []*Problem) bool {
+	for _, p := range problems {
+		if p.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// the request kind a field applies to
+type requestKind string
+
+const (
+	txRequest      requestKind = "transaction"
+	eip712Request  requestKind = "eip712"
+	messageRequest requestKind = "message"
+)
+
+// the value type of a field, it decides which symbols and values are valid
+type valueKind int
+
+const (
+	addressValue valueKind = iota
+	numberValue
+	stringValue
+	selectorValue
+	paramValue
+	accessListValue
+	eip712MessageValue
+)
+
+var fieldKinds = map[Field]struct {
+	request requestKind
+	value   valueKind
+}{
+	FromField:                     {txRequest, addressValue},
+	ToField:                       {txRequest, addressValue},
+	ValueField:                    {txRequest, numberValue},
+	DataSelectorField:             {txRequest, selectorValue},
+	DataField:                     {txRequest, stringValue},
+	DataParamField:                {txRequest, paramValue},
+	GasField:                      {txRequest, numberValue},
+	GasPriceField:                 {txRequest, numberValue},
+	MaxFeePerGasField:             {txRequest, numberValue},
+	MaxPriorityFeePerGasField:     {txRequest, numberValue},
+	FeeField:                      {txRequest, numberValue},
+	TotalCostField:                {txRequest, numberValue},
+	TxTypeField:                   {txRequest, numberValue},
+	NonceField:                    {txRequest, numberValue},
+	AccessListField:               {txRequest, accessListValue},
+	MessageField:                  {messageRequest, stringValue},
+	Eip712DomainName:              {eip712Request, stringValue},
+	Eip712DomainVersion:           {eip712Request, stringValue},
+	Eip712DomainChainId:           {eip712Request, numberValue},
+	Eip712DomainVerifyingContract: {eip712Request, addressValue},
+	Eip712PrimaryType:             {eip712Request, stringValue},
+}
+
+var valueSymbols = map[valueKind][]Symbol{
+	addressValue:       {EqualSymbol, InSymbol, ContainsSymbol, RegexSymbol},
+	numberValue:        {EqualSymbol, GrateAndEqualSymbol, LessAndEqualSymbol, InSymbol},
+	stringValue:        {EqualSymbol, InSymbol, ContainsSymbol, RegexSymbol},
+	selectorValue:      {EqualSymbol, InSymbol, ContainsSymbol, RegexSymbol},
+	accessListValue:    {EqualSymbol, InSymbol, ContainsSymbol},
+	eip712MessageValue: {EqualSymbol, GrateAndEqualSymbol, LessAndEqualSymbol, InSymbol, ContainsSymbol, RegexSymbol},
+}
+
+var (
+	selectorRegexp     = regexp.MustCompile(`^0x[0-9a-f]{8}$`)
+	eip712MessageRegex = regexp.MustCompile(`^eip712\.message\.[A-Za-z_$][A-Za-z0-9_$]*$`)
+)
+
+// Lint checks the rules of a JSON or YAML rule file, problems carry the file locations.
+// chainIds are the configured chains, nil skips the chain check
+func Lint(file string, data []byte, chainIds map[int64]bool) []*Problem {
+	var problems []*Problem
+	fileProblem := func(msg string) []*Problem {
+		return append(problems, &Problem{File: file, Rule: -1, Level: LevelError, Msg: msg})
+	}
+
+	data, positions, err := Decode(file, data)
+	if err != nil {
+		return fileProblem(fmt.Sprintf("invalid rule file: %s", err))
+	}
+	var raws []json.RawMessage
+	if err = json.Unmarshal(data, &raws); err != nil {
+		return fileProblem(fmt.Sprintf("invalid rule file: %s", err))
+	}
+
+	names := make(map[string]int)
+	for i, raw := range raws {
+		var ruleProblems []*Problem
+		rule := new(Rule)
+		if err = json.Unmarshal(raw, &rule); err != nil {
+			ruleProblems = []*Problem{{Level: LevelError, Msg: fmt.Sprintf("invalid rule: %s", err)}}
+		} else if rule == nil {
+			ruleProblems = []*Problem{{Level: LevelError, Msg: "rule is null"}}
+		} else {
+			ruleProblems = rule.Lint(chainIds)
+			if first, ok := names[rule.Name]; ok && rule.Name != "" {
+				ruleProblems = append(ruleProblems, &Problem{Path: "name", Level: LevelError,
+					Msg: fmt.Sprintf("name is duplicated with rule[%d]", first)})
+			} else {
+				names[rule.Name] = i
+			}
+
+			var generic interface{}
+			_ = json.Unmarshal(raw, &generic)
+			for _, path := range unknownKeys(generic, reflect.TypeOf(Rule{}), "") {
+				ruleProblems = append(ruleProblems, &Problem{Path: path, Level: LevelError, Msg: "unknown key"})
+			}
+		}
+
+		for _, p := range ruleProblems {
+			p.File = file
+			p.Rule = i
+			if rule != nil {
+				p.Name = rule.Name
+			}
+			path := fmt.Sprintf("[%d]", i)
+			if p.Path != "" {
+				path += "." + p.Path
+			}
+			if pos, ok := positions.Lookup(path); ok {
+				p.Line, p.Column = pos.Line, pos.Column
+			}
+		}
+		problems = append(problems, ruleProblems...)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+	return problems
+}
+
+// Lint checks a rule without its file location, the problem paths are relative to the rule
+func (r *Rule) Lint(chainIds map[int64]bool) []*Problem {
+	var problems []*Problem
+	report := func(level Level, path, format string, args ...interface{}) {
+		problems = append(problems, &Problem{Path: path, Level: level, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if r.Name == "" {
+		report(LevelError, "name", "name is required")
+	}
+	if r.ChainId <= 0 {
+		report(LevelError, "chain_id", "chain_id must be > 0")
+	} else if chainIds != nil && !chainIds[r.ChainId] {
+		report(LevelError, "chain_id", "chain_id %d is not configured in chains", r.ChainId)
+	}
+	if err := r.Validate(); err != nil {
+		report(LevelError, "", "%s", err)
+	}
+
+	now := time.Now()
+	if r.IsExpired(now) {
+		report(LevelWarn, "not_after", "expired at %s, it never matches", r.NotAfter.Format(time.RFC3339))
+	} else if r.NotBefore != nil && now.Before(*r.NotBefore) {
+		report(LevelInfo, "not_before", "not live before %s", r.NotBefore.Format(time.RFC3339))
+	}
+
+	if r.Conditions == nil {
+		report(LevelError, "conditions", "conditions is required, a rule without conditions never matches, use [] to match every request on the chain")
+		return problems
+	}
+	if len(*r.Conditions) == 0 {
+		if r.Script != "" {
+			return problems
+		}
+		report(LevelWarn, "conditions", "no conditions, the rule matches every request on the chain")
+		return problems
+	}
+
+	requests := make(map[requestKind]bool)
+	for i, c := range *r.Conditions {
+		path := fmt.Sprintf("conditions[%d]", i)
+		if c == nil {
+			report(LevelError, path, "condition is null")
+			continue
+		}
+		request, ps := c.lint()
+		for _, p := range ps {
+			p.Path = path + "." + p.Path
+		}
+		problems = append(problems, ps...)
+		if request != "" {
+			requests[request] = true
+		}
+	}
+	if len(requests) > 1 {
+		var kinds []string
+		for kind := range requests {
+			kinds = append(kinds, string(kind))
+		}
+		sort.Strings(kinds)
+		report(LevelError, "conditions", "conditions on %s fields never match the same request", strings.Join(kinds, " and "))
+	}
+	return problems
+}
+
+// lint checks the field, symbol and value of a condition, it returns the request kind the field applies to
+func (c *Condition) lint() (requestKind, []*Problem) {
+	var problems []*Problem
+	report := func(path, format string, args ...interface{}) {
+		problems = append(problems, &Problem{Path: path, Level: LevelError, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	var request requestKind
+	var kind valueKind
+	if fk, ok := fieldKinds[c.Field]; ok {
+		request, kind = fk.request, fk.value
+	} else if eip712MessageRegex.MatchString(string(c.Field)) {
+		request, kind = eip712Request, eip712MessageValue
+	} else {
+		report("field", "unknown field %q", c.Field)
+		return "", problems
+	}
+
+	var abiInputs abi.Arguments
+	if c.Abi != "" {
+		_abi, err := abi.JSON(strings.NewReader("[" + c.Abi + "]"))
+		if err != nil {
+			report("abi", "can not parse abi: %s", err)
+		} else if len(_abi.Methods) != 1 {
+			report("abi", "abi must declare exactly one function, got %d", len(_abi.Methods))
+		} else {
+			for _, method := range _abi.Methods {
+				abiInputs = method.Inputs
+			}
+		}
+	}
+
+	value := strings.ToLower(c.Value)
+	if kind == paramValue {
+		if c.Abi == "" {
+			report("abi", "data_param requires an abi")
+		}
+		if c.Param == "" {
+			report("param", "data_param requires a param")
+		}
+		kind = -1
+		if abiInputs != nil && c.Param != "" {
+			found := false
+			for _, arg := range abiInputs {
+				if arg.Name != c.Param {
+					continue
+				}
+				found = true
+				switch {
+				case (arg.Type.T == abi.UintTy || arg.Type.T == abi.IntTy) && arg.Type.Size > 64:
+					kind = numberValue
+				case arg.Type.T == abi.StringTy:
+					kind = stringValue
+				default:
+					report("param", "param %s of type %s is not supported, use a string or an (u)int wider than 64 bits",
+						c.Param, arg.Type.String())
+				}
+			}
+			if !found {
+				report("param", "param %s not found in abi", c.Param)
+			}
+		}
+		if kind == -1 {
+			return request, problems
+		}
+	}
+
+	symbols := valueSymbols[kind]
+	if !symbolIn(c.Symbol, symbols) {
+		if !symbolIn(c.Symbol, []Symbol{EqualSymbol, GrateAndEqualSymbol, InSymbol, LessAndEqualSymbol, ContainsSymbol, RegexSymbol}) {
+			report("symbol", "unknown symbol %q", c.Symbol)
+		} else {
+			report("symbol", "symbol %q is not supported for field %s, use one of %s", c.Symbol, c.Field, joinSymbols(symbols))
+		}
+		return request, problems
+	}
+
+	if c.Symbol == RegexSymbol {
+		if _, err := regexp.Compile(value); err != nil {
+			report("value", "invalid regex: %s", err)
+		}
+		return request, problems
+	}
+	if c.Symbol == ContainsSymbol && kind != accessListValue {
+		return request, problems
+	}
+
+	values := []string{value}
+	if c.Symbol == InSymbol || kind == accessListValue && c.Symbol == EqualSymbol {
+		values = strings.Split(value, ",")
+	}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		switch kind {
+		case addressValue, accessListValue:
+			if kind == accessListValue && c.Symbol == EqualSymbol && value == "" {
+				continue
+			}
+			if !common.IsHexAddress(v) || !strings.HasPrefix(v, "0x") {
+				report("value", "%q is not an address", v)
+			}
+		case numberValue:
+			if _, ok := new(big.Int).SetString(v, 10); !ok {
+				report("value", "%q is not a decimal number", v)
+			}
+		case selectorValue:
+			if !selectorRegexp.MatchString(v) {
+				report("value", "%q is not a 4 byte selector, eg. 0xa9059cbb", v)
+			}
+		}
+	}
+	return request, problems
+}
+
+func symbolIn(symbol Symbol, symbols []Symbol) bool {
+	for _, s := range symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+func joinSymbols(symbols []Symbol) string {
+	strs := make([]string, 0, len(symbols))
+	for _, s := range symbols {
+		strs = append(strs, string(s))
+	}
+	return strings.Join(strs, ", ")
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownKeys the paths of the object keys which don't map to a json field of the type
+func unknownKeys(v interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	var unknown []string
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field, ok := jsonField(t, key)
+			if !ok {
+				unknown = append(unknown, join(key))
+				continue
+			}
+			unknown = append(unknown, unknownKeys(obj[key], field.Type, join(key))...)
+		}
+	case reflect.Slice:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, item := range arr {
+			unknown = append(unknown, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return unknown
+}
+
+// jsonField the struct field a json key decodes into, matched case-insensitively like encoding/json
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,12 @@
+package rules
+
+import (
+	"evm-signer/pkg/logging"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	SetLogger(logging.GetLogger("signer", "test", &logging.LogConfig{Level: "error"}).Sugar())
+	os.Exit(m.Run())
+}
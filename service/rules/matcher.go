@@ -0,0 +1,195 @@
+package rules
+
+import (
+	"evm-signer/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"strings"
+)
+
+// RuleMatcher finds the first rule which allows a request
+type RuleMatcher interface {
+	GetMatched(chainId int64, tx *types.Transaction) *Rule
+	GetMatchedEip712(chainId int64, eip712Msg *apitypes.TypedData) *Rule
+	GetMatchedMessage(chainId int64, message string) *Rule
+}
+
+// Matcher the rules indexed at load time by chain id, and for transactions by the to address
+// and the selector a rule requires. Only the candidate rules are evaluated, in file order,
+// so it returns the same rule as the linear match of Rules
+type Matcher struct {
+	rules  Rules
+	chains map[int64]*chainIndex
+}
+
+type chainIndex struct {
+	rules []int           // positions of the chain rules
+	tx    map[txKey][]int // positions of the transaction candidates, ascending
+}
+
+// txKey a bucket of the transaction index, any means the rule has no constraint on the part
+type txKey struct {
+	to          string
+	anyTo       bool
+	selector    string
+	anySelector bool
+}
+
+// Compile indexes initialized rules
+func Compile(rs Rules) *Matcher {
+	m := &Matcher{
+		rules:  rs,
+		chains: make(map[int64]*chainIndex),
+	}
+	for i, rule := range rs {
+		index, ok := m.chains[rule.ChainId]
+		if !ok {
+			index = &chainIndex{tx: make(map[txKey][]int)}
+			m.chains[rule.ChainId] = index
+		}
+		index.rules = append(index.rules, i)
+
+		tos := rule.indexValues(ToField)
+		selectors := rule.indexValues(DataSelectorField)
+		for _, to := range keysOrAny(tos) {
+			for _, selector := range keysOrAny(selectors) {
+				key := txKey{anyTo: tos == nil, anySelector: selectors == nil}
+				if to != nil {
+					key.to = *to
+				}
+				if selector != nil {
+					key.selector = *selector
+				}
+				index.tx[key] = append(index.tx[key], i)
+			}
+		}
+	}
+	return m
+}
+
+// indexValues the values one of which the field must equal for the rule to match,
+// nil when the rule has no such constraint or it can't be indexed
+func (r *Rule) indexValues(field Field) []string {
+	if r.Conditions == nil {
+		return nil
+	}
+	for _, c := range *r.Conditions {
+		if c.Field != field {
+			continue
+		}
+		var values []string
+		switch c.Symbol {
+		case EqualSymbol:
+			values = []string{c.Value}
+		case InSymbol:
+			values = c.inValues()
+		default:
+			continue
+		}
+		// the match is case-insensitive, only ASCII values are indexed by their lower case
+		for _, v := range values {
+			if !isASCII(v) {
+				return nil
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func keysOrAny(values []string) []*string {
+	if values == nil {
+		return []*string{nil}
+	}
+	keys := make([]*string, 0, len(values))
+	seen := make(map[string]bool)
+	for i := range values {
+		if !seen[values[i]] {
+			seen[values[i]] = true
+			keys = append(keys, &values[i])
+		}
+	}
+	return keys
+}
+
+func (m *Matcher) GetMatched(chainId int64, tx *types.Transaction) *Rule {
+	index, ok := m.chains[chainId]
+	if !ok {
+		return nil
+	}
+
+	to := strings.ToLower(tx.To)
+	selector := ""
+	if len(tx.Input) >= 10 {
+		selector = strings.ToLower(tx.Input[0:10])
+	}
+	if !isASCII(to) || !isASCII(selector) {
+		return m.first(index.rules, func(rule *Rule) bool { return rule.IsMatch(chainId, tx) })
+	}
+
+	buckets := [][]int{index.tx[txKey{anyTo: true, anySelector: true}], index.tx[txKey{to: to, anySelector: true}]}
+	if selector != "" {
+		buckets = append(buckets, index.tx[txKey{anyTo: true, selector: selector}], index.tx[txKey{to: to, selector: selector}])
+	}
+	return m.first(mergeSorted(buckets), func(rule *Rule) bool { return rule.IsMatch(chainId, tx) })
+}
+
+func (m *Matcher) GetMatchedEip712(chainId int64, eip712Msg *apitypes.TypedData) *Rule {
+	index, ok := m.chains[chainId]
+	if !ok {
+		return nil
+	}
+	return m.first(index.rules, func(rule *Rule) bool {
+		if rule.IsMatch712(chainId, eip712Msg) {
+			return true
+		}
+		logger.Infof("[RuleNotMatch] %s", rule.Name)
+		return false
+	})
+}
+
+func (m *Matcher) GetMatchedMessage(chainId int64, message string) *Rule {
+	index, ok := m.chains[chainId]
+	if !ok {
+		return nil
+	}
+	return m.first(index.rules, func(rule *Rule) bool { return rule.IsMatchMessage(chainId, message) })
+}
+
+func (m *Matcher) first(positions []int, isMatch func(rule *Rule) bool) *Rule {
+	for _, i := range positions {
+		if isMatch(m.rules[i]) {
+			return m.rules[i]
+		}
+	}
+	return nil
+}
+
+// mergeSorted merges ascending position lists, the buckets of a lookup never share a position
+func mergeSorted(lists [][]int) []int {
+	total := 0
+	for _, list := range lists {
+		total += len(list)
+	}
+	merged := make([]int, 0, total)
+	heads := make([]int, len(lists))
+	for len(merged) < total {
+		min := -1
+		for i, list := range lists {
+			if heads[i] < len(list) && (min == -1 || list[heads[i]] < lists[min][heads[min]]) {
+				min = i
+			}
+		}
+		merged = append(merged, lists[min][heads[min]])
+		heads[min]++
+	}
+	return merged
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
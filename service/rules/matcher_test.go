@@ -0,0 +1,116 @@
+package rules
+
+import (
+	"evm-signer/types"
+	"fmt"
+	"testing"
+)
+
+func testRule(name string, chainId int64, conditions ...*Condition) *Rule {
+	_conditions := Conditions(conditions)
+	return &Rule{Name: name, ChainId: chainId, Conditions: &_conditions}
+}
+
+func TestMatcherEquivalence(t *testing.T) {
+	rs := Rules{
+		testRule("value_limit", 1, &Condition{Field: ValueField, Symbol: LessAndEqualSymbol, Value: "10"}),
+		testRule("mixed_case_to", 1, &Condition{Field: ToField, Symbol: EqualSymbol, Value: "0xAbCdEf0000000000000000000000000000000001"}),
+		testRule("to_and_selector", 1,
+			&Condition{Field: ToField, Symbol: EqualSymbol, Value: "0x0000000000000000000000000000000000000002"},
+			&Condition{Field: DataSelectorField, Symbol: EqualSymbol, Value: "0xA9059CBB"}),
+		testRule("selector_only", 1, &Condition{Field: DataSelectorField, Symbol: InSymbol, Value: "0x095ea7b3,0xa9059cbb"}),
+		testRule("to_in", 1, &Condition{Field: ToField, Symbol: InSymbol, Value: "0x0000000000000000000000000000000000000003,0x0000000000000000000000000000000000000004"}),
+		testRule("non_ascii_to", 1, &Condition{Field: ToField, Symbol: EqualSymbol, Value: "0xſtate"}),
+		testRule("kelvin_to", 1, &Condition{Field: ToField, Symbol: EqualSymbol, Value: "0xKeep"}),
+		testRule("non_ascii_in", 1, &Condition{Field: ToField, Symbol: InSymbol, Value: "0xÄbc,0x0000000000000000000000000000000000000005"}),
+		testRule("to_contains", 1, &Condition{Field: ToField, Symbol: ContainsSymbol, Value: "dead"}),
+		testRule("mixed_case_to", 1, &Condition{Field: ToField, Symbol: EqualSymbol, Value: "0xabcdef0000000000000000000000000000000001"}),
+		testRule("other_chain", 56, &Condition{Field: ToField, Symbol: EqualSymbol, Value: "0x0000000000000000000000000000000000000002"}),
+		testRule("catch_all", 1),
+	}
+	rs.Init()
+	matcher := Compile(rs)
+
+	tos := []string{
+		"0xabcdef0000000000000000000000000000000001", "0xABCDEF0000000000000000000000000000000001",
+		"0x0000000000000000000000000000000000000002", "0x0000000000000000000000000000000000000004",
+		"0x0000000000000000000000000000000000000005", "0x000000000000000000000000000000000000dEaD",
+		"0xstate", "0xSTATE", "0xſtate", "0xkeep", "0xKEEP", "0xKeep", "0xäbc", "0xÄBC", "0xabc",
+		"0x0000000000000000000000000000000000000009", "",
+	}
+	inputs := []string{"", "0x", "0xa9059cbb0000", "0xA9059CBB0000", "0x095ea7b3", "0x12345678"}
+	values := []string{"1", "100"}
+	for _, chainId := range []int64{1, 56, 137} {
+		for _, to := range tos {
+			for _, input := range inputs {
+				for _, value := range values {
+					tx := func() *types.Transaction { return &types.Transaction{To: to, Input: input, Value: value} }
+					want := rs.GetMatched(chainId, tx())
+					if got := matcher.GetMatched(chainId, tx()); got != want {
+						t.Errorf("chain %d to %q input %q value %s: indexed %s, linear %s",
+							chainId, to, input, value, ruleName(got), ruleName(want))
+					}
+				}
+			}
+		}
+	}
+}
+
+func ruleName(rule *Rule) string {
+	if rule == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%s@%p", rule.Name, rule)
+}
+
+// benchRules n rules, each bound to its own to address and one of a few selectors
+func benchRules(n int) Rules {
+	selectors := []string{"0xa9059cbb", "0x095ea7b3", "0x23b872dd", "0x2e1a7d4d"}
+	rs := make(Rules, 0, n)
+	for i := 0; i < n; i++ {
+		rs = append(rs, testRule(fmt.Sprintf("rule%d", i), 1,
+			&Condition{Field: ToField, Symbol: EqualSymbol, Value: fmt.Sprintf("0x%040x", i)},
+			&Condition{Field: DataSelectorField, Symbol: EqualSymbol, Value: selectors[i%len(selectors)]},
+			&Condition{Field: ValueField, Symbol: LessAndEqualSymbol, Value: "1000"}))
+	}
+	rs.Init()
+	return rs
+}
+
+func benchGetMatched(b *testing.B, getMatched func(rs Rules) func(chainId int64, tx *types.Transaction) *Rule) {
+	for _, n := range []int{100, 1000, 10000} {
+		rs := benchRules(n)
+		match := getMatched(rs)
+		last := n - 1
+		cases := []struct {
+			name string
+			tx   types.Transaction
+			want *Rule
+		}{
+			{"last", types.Transaction{To: fmt.Sprintf("0x%040x", last), Input: "0x2e1a7d4d", Value: "1"}, rs[last]},
+			{"no_match", types.Transaction{To: fmt.Sprintf("0x%040x", n), Input: "0xa9059cbb", Value: "1"}, nil},
+		}
+		for _, c := range cases {
+			b.Run(fmt.Sprintf("rules=%d/%s", n, c.name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					tx := c.tx
+					if got := match(1, &tx); got != c.want {
+						b.Fatalf("matched %s, want %s", ruleName(got), ruleName(c.want))
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkGetMatchedIndexed(b *testing.B) {
+	benchGetMatched(b, func(rs Rules) func(chainId int64, tx *types.Transaction) *Rule {
+		return Compile(rs).GetMatched
+	})
+}
+
+func BenchmarkGetMatchedLinear(b *testing.B) {
+	benchGetMatched(b, func(rs Rules) func(chainId int64, tx *types.Transaction) *Rule {
+		return rs.GetMatched
+	})
+}
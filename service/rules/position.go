@@ -0,0 +1,102 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Position a location in a rule file
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Positions maps the paths of a rule file, eg. [0].conditions[1].value, to their location
+type Positions map[string]Position
+
+// Lookup the location of the path, or of its closest parent
+func (p Positions) Lookup(path string) (Position, bool) {
+	for {
+		if pos, ok := p[path]; ok {
+			return pos, true
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i <= 0 {
+			return Position{}, false
+		}
+		path = path[:i]
+	}
+}
+
+// LocateJSON the positions of the object keys and array elements of a JSON document
+func LocateJSON(data []byte) (Positions, error) {
+	positions := make(Positions)
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		if _, ok := positions[path]; !ok {
+			positions[path] = offsetPosition(data, skipSeparators(data, int(dec.InputOffset())))
+		}
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		delim, ok := tok.(json.Delim)
+		if !ok {
+			return nil
+		}
+		switch delim {
+		case '{':
+			for dec.More() {
+				start := skipSeparators(data, int(dec.InputOffset()))
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				keyPath := fmt.Sprintf("%s.%s", path, key)
+				positions[keyPath] = offsetPosition(data, start)
+				if err = walk(keyPath); err != nil {
+					return err
+				}
+			}
+		case '[':
+			for i := 0; dec.More(); i++ {
+				if err = walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+		_, err = dec.Token()
+		return err
+	}
+
+	if err := walk(""); err != nil {
+		return positions, err
+	}
+	return positions, nil
+}
+
+// skipSeparators moves the offset past white space, commas and colons to the start of the next token
+func skipSeparators(data []byte, offset int) int {
+	for offset < len(data) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+func offsetPosition(data []byte, offset int) Position {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return Position{Line: line, Column: column}
+}
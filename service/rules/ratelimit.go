@@ -0,0 +1,37 @@
+package rules
+
+import (
+	"fmt"
+	"golang.org/x/time/rate"
+	"time"
+)
+
+// RateLimit a token bucket of Limit requests per Period, Burst defaults to Limit
+type RateLimit struct {
+	Limit  int    `json:"limit" mapstructure:"limit"`
+	Period string `json:"period" mapstructure:"period"` // eg. 1s, 1m, 1h
+	Burst  int    `json:"burst" mapstructure:"burst"`
+}
+
+// Init validates the rate limit and returns the token rate and the bucket size
+func (r *RateLimit) Init() (rate.Limit, int, error) {
+	if r.Limit <= 0 {
+		return 0, 0, fmt.Errorf("rate limit must be > 0, got %d", r.Limit)
+	}
+	period := time.Second
+	if r.Period != "" {
+		var err error
+		period, err = time.ParseDuration(r.Period)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid rate limit period %s: %s", r.Period, err)
+		}
+		if period <= 0 {
+			return 0, 0, fmt.Errorf("rate limit period must be > 0, got %s", r.Period)
+		}
+	}
+	burst := r.Burst
+	if burst <= 0 {
+		burst = r.Limit
+	}
+	return rate.Every(period / time.Duration(r.Limit)), burst, nil
+}
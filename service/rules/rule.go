@@ -1,10 +1,15 @@
 package rules
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"evm-signer/pkg/logging"
 	"evm-signer/types"
+	"fmt"
 	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"strings"
+	"time"
 )
 
 type Rules []*Rule
@@ -19,6 +24,16 @@ func (c Rules) Length() int {
 	return len(c)
 }
 
+// Hash the sha256 of the rule set content, it identifies the rule version in use
+func (c Rules) Hash() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func (c Rules) GetMatched(chainId int64, tx *types.Transaction) *Rule {
 	for _, rule := range c {
 		isMatch := rule.IsMatch(chainId, tx)
@@ -62,42 +77,162 @@ type Rule struct {
 	Name       string      `json:"name" mapstructure:"name"`
 	ChainId    int64       `json:"chain_id" mapstructure:"chain_id"`
 	Conditions *Conditions `json:"conditions" mapstructure:"conditions"`
+	RateLimit  *RateLimit  `json:"rate_limit,omitempty" mapstructure:"rate_limit"`
+	NotBefore  *time.Time  `json:"not_before,omitempty" mapstructure:"not_before"` // RFC3339
+	NotAfter   *time.Time  `json:"not_after,omitempty" mapstructure:"not_after"`   // RFC3339
+	Windows    []*Window   `json:"windows,omitempty" mapstructure:"windows"`       // the rule is live in any of the windows
+
+	RequireApproval bool `json:"require_approval,omitempty" mapstructure:"require_approval"` // park matched requests for human approval
+	Approvals       int  `json:"approvals,omitempty" mapstructure:"approvals"`               // approvals needed, default 1
+
+	// a JS check(request, lists) which must also return true, inline or a .js file under the script dir
+	Script string `json:"script,omitempty" mapstructure:"script"`
+	Abi    string `json:"abi,omitempty" mapstructure:"abi"` // decodes the transaction data into request.call for the script
+
+	script *Script
+	file   string // the rule file, position and location the rule is defined at
+	index  int
+	line   int
+	column int
+}
+
+// IsActive whether the rule is live at t
+func (r *Rule) IsActive(t time.Time) bool {
+	if r.NotBefore != nil && t.Before(*r.NotBefore) {
+		return false
+	}
+	if r.IsExpired(t) {
+		return false
+	}
+	if len(r.Windows) == 0 {
+		return true
+	}
+	for _, window := range r.Windows {
+		if window.IsActive(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired whether the rule will never be live again after t
+func (r *Rule) IsExpired(t time.Time) bool {
+	return r.NotAfter != nil && !t.Before(*r.NotAfter)
 }
 
 func (r *Rule) IsMatch(chainId int64, tx *types.Transaction) bool {
 	if r.ChainId != chainId {
 		return false
 	}
+	if !r.IsActive(time.Now()) {
+		return false
+	}
 	tx.From = strings.ToLower(tx.From)
 	tx.To = strings.ToLower(tx.To)
-	if !r.Conditions.IsMatch(tx) {
+	if r.Conditions == nil || !r.Conditions.IsMatch(tx) {
 		return false
 	}
-	return true
+	return r.checkScript(func(s *Script) map[string]interface{} { return s.txRequest(chainId, tx) })
 }
 
 func (r *Rule) IsMatch712(chainId int64, eip712Msg *apitypes.TypedData) bool {
 	if r.ChainId != chainId {
 		return false
 	}
+	if !r.IsActive(time.Now()) {
+		return false
+	}
 
-	if !r.Conditions.IsMatch712(eip712Msg) {
+	if r.Conditions == nil || !r.Conditions.IsMatch712(eip712Msg) {
 		return false
 	}
-	return true
+	return r.checkScript(func(s *Script) map[string]interface{} { return s.eip712Request(chainId, eip712Msg) })
 }
 
 func (r *Rule) IsMatchMessage(chainId int64, message string) bool {
 	if r.ChainId != chainId {
 		return false
 	}
+	if !r.IsActive(time.Now()) {
+		return false
+	}
+
+	if r.Conditions == nil || !r.Conditions.IsMatchMessage(strings.ToLower(message)) {
+		return false
+	}
+	return r.checkScript(func(s *Script) map[string]interface{} { return s.messageRequest(chainId, message) })
+}
+
+// checkScript runs the script of the rule, a script which failed to load or errors never matches
+func (r *Rule) checkScript(request func(s *Script) map[string]interface{}) bool {
+	if r.Script == "" {
+		return true
+	}
+	if r.script == nil {
+		logger.Warnf("[ScriptNotLoaded] rule [ %s ] script is not loaded, check the rule config", r.Name)
+		return false
+	}
+	allowed, err := r.script.Check(request(r.script))
+	if err != nil {
+		logger.Warnf("[ScriptError] rule [ %s ]: %s", r.Name, err)
+		return false
+	}
+	return allowed
+}
 
-	if !r.Conditions.IsMatchMessage(strings.ToLower(message)) {
+// BoundsField whether the rule sets its own upper bound on the field, a `<=`, `==` or `in` condition.
+// A lower bound like `>=` doesn't
+func (r *Rule) BoundsField(field Field) bool {
+	if r.Conditions == nil {
 		return false
 	}
-	return true
+	for _, condition := range *r.Conditions {
+		if condition.Field != field {
+			continue
+		}
+		switch condition.Symbol {
+		case LessAndEqualSymbol, EqualSymbol, InSymbol:
+			return true
+		}
+	}
+	return false
 }
 
 func (r *Rule) Init() {
-	r.Conditions.Init()
+	if r.Conditions != nil {
+		r.Conditions.Init()
+	}
+	if err := r.Validate(); err != nil {
+		logger.Warnf("rule [ %s ] config error: %s", r.Name, err.Error())
+	}
+}
+
+// Validate checks the rate limit and the time constraints of the rule,
+// it initializes the windows and compiles the script
+func (r *Rule) Validate() error {
+	if r.RateLimit != nil {
+		if _, _, err := r.RateLimit.Init(); err != nil {
+			return err
+		}
+	}
+	if r.NotBefore != nil && r.NotAfter != nil && !r.NotBefore.Before(*r.NotAfter) {
+		return fmt.Errorf("not_before %s is not before not_after %s",
+			r.NotBefore.Format(time.RFC3339), r.NotAfter.Format(time.RFC3339))
+	}
+	for i, window := range r.Windows {
+		if err := window.Init(); err != nil {
+			return fmt.Errorf("windows[%d]: %s", i, err)
+		}
+	}
+	r.script = nil
+	if r.Script != "" {
+		script, err := compileScript(r.Name, r.Script, r.Abi)
+		if err != nil {
+			return fmt.Errorf("script: %s", err)
+		}
+		r.script = script
+	} else if r.Abi != "" {
+		return fmt.Errorf("abi is only used by a script")
+	}
+	return nil
 }
@@ -0,0 +1,387 @@
+package rules
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"evm-signer/types"
+	"fmt"
+	"github.com/dop251/goja"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	defaultScriptTimeout  = 50 * time.Millisecond
+	defaultScriptMaxStack = 256
+)
+
+type ScriptConfig struct {
+	Dir          string              `mapstructure:"dir"`            // base dir of the script files, default the rule file dir
+	Timeout      string              `mapstructure:"timeout"`        // time limit of one check, default 50ms
+	MaxCallStack int                 `mapstructure:"max_call_stack"` // default 256
+	Lists        map[string][]string `mapstructure:"lists"`          // named lists, read-only in the scripts
+	ListFiles    map[string]string   `mapstructure:"list_files"`     // named lists loaded from files, one value per line
+
+	timeout time.Duration
+	lists   string // the lists as JSON
+}
+
+var scriptConfig = &ScriptConfig{timeout: defaultScriptTimeout, MaxCallStack: defaultScriptMaxStack, lists: "{}"}
+
+// SetScriptConfig applies the limits and loads the named lists of the script rules,
+// it must be called before the rules are initialized
+func SetScriptConfig(config *ScriptConfig) error {
+	config.timeout = defaultScriptTimeout
+	if config.Timeout != "" {
+		timeout, err := time.ParseDuration(config.Timeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid script timeout %s", config.Timeout)
+		}
+		config.timeout = timeout
+	}
+	if config.MaxCallStack <= 0 {
+		config.MaxCallStack = defaultScriptMaxStack
+	}
+
+	lists := make(map[string][]string)
+	for name, values := range config.Lists {
+		lists[name] = values
+	}
+	for name, file := range config.ListFiles {
+		if _, ok := lists[name]; ok {
+			return fmt.Errorf("script list %s is defined twice", name)
+		}
+		values, err := readList(file)
+		if err != nil {
+			return fmt.Errorf("load script list %s error: %s", name, err)
+		}
+		lists[name] = values
+	}
+	data, err := json.Marshal(lists)
+	if err != nil {
+		return err
+	}
+	config.lists = string(data)
+
+	scriptConfig = config
+	return nil
+}
+
+// readList reads one value per line, blank lines and lines starting with # are skipped
+func readList(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		values = append(values, line)
+	}
+	return values, scanner.Err()
+}
+
+// the prelude of every check, request and lists are frozen before the script sees them
+var scriptPrelude = goja.MustCompile("prelude", `
+function __freeze(o) {
+	if (o !== null && typeof o === "object" && !Object.isFrozen(o)) {
+		Object.freeze(o);
+		Object.getOwnPropertyNames(o).forEach(function (k) { __freeze(o[k]); });
+	}
+	return o;
+}
+`, true)
+
+// Script a compiled rule script, it defines check(request, lists) which returns true to allow
+type Script struct {
+	name    string
+	program *goja.Program
+	abi     *abi.ABI // decodes the transaction data for the script, optional
+}
+
+// compileScript compiles the script of the rule, a source ending with .js is a file under the script dir
+func compileScript(name, source, abiJSON string) (*Script, error) {
+	if strings.HasSuffix(source, ".js") {
+		path := source
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(scriptConfig.Dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		name, source = path, string(data)
+	}
+
+	program, err := goja.Compile(name, source, true)
+	if err != nil {
+		return nil, err
+	}
+	script := &Script{name: name, program: program}
+
+	if abiJSON != "" {
+		if !strings.HasPrefix(strings.TrimSpace(abiJSON), "[") {
+			abiJSON = "[" + abiJSON + "]"
+		}
+		_abi, err := abi.JSON(strings.NewReader(abiJSON))
+		if err != nil {
+			return nil, fmt.Errorf("invalid abi: %s", err)
+		}
+		script.abi = &_abi
+	}
+
+	// the script must load and define check
+	if _, err = script.run("true", nil); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// Check runs check(request, lists) in a new sandboxed runtime, anything but true denies
+func (s *Script) Check(request map[string]interface{}) (bool, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return false, err
+	}
+	result, err := s.run(`check(__freeze(JSON.parse(__request)), __freeze(JSON.parse(__lists)))`, data)
+	if err != nil {
+		return false, err
+	}
+	allowed, ok := result.Export().(bool)
+	if !ok {
+		return false, fmt.Errorf("check returned %s, not a boolean", result.String())
+	}
+	return allowed, nil
+}
+
+// run loads the script into a fresh runtime and evaluates expr within the time limit
+func (s *Script) run(expr string, request []byte) (goja.Value, error) {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(scriptConfig.MaxCallStack)
+	vm.Set("__request", string(request))
+	vm.Set("__lists", scriptConfig.lists)
+	vm.Set("console", map[string]interface{}{
+		"log": func(args ...interface{}) {
+			logger.Infof("[Script %s] %s", s.name, fmt.Sprint(args...))
+		},
+	})
+	vm.Set("big", scriptBig(vm))
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.watch(vm, done)
+
+	if _, err := vm.RunProgram(scriptPrelude); err != nil {
+		return nil, err
+	}
+	if _, err := vm.RunProgram(s.program); err != nil {
+		return nil, err
+	}
+	valid, err := vm.RunString(`typeof check === "function"`)
+	if err != nil {
+		return nil, err
+	}
+	if !valid.ToBoolean() {
+		return nil, fmt.Errorf("script does not define function check(request, lists)")
+	}
+	return vm.RunString(expr)
+}
+
+// watch interrupts the runtime when it runs out of time. goja doesn't account the memory of a runtime,
+// so memory is not limited: a script allocating in a loop is stopped by the time limit only
+func (s *Script) watch(vm *goja.Runtime, done chan struct{}) {
+	timeout := time.NewTimer(scriptConfig.timeout)
+	defer timeout.Stop()
+	select {
+	case <-done:
+	case <-timeout.C:
+		vm.Interrupt(fmt.Errorf("script exceeded the %s time limit", scriptConfig.timeout))
+	}
+}
+
+// scriptBig integer arithmetic on decimal or hex strings, JS numbers can't hold wei amounts
+func scriptBig(vm *goja.Runtime) map[string]interface{} {
+	arg := func(call goja.FunctionCall, i int) *big.Int {
+		str := call.Argument(i).String()
+		value, ok := ParseTxNumber(str)
+		if !ok {
+			panic(vm.NewTypeError("big: %q is not a number", str))
+		}
+		return value
+	}
+	op := func(f func(a, b *big.Int) *big.Int) func(call goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			return vm.ToValue(f(arg(call, 0), arg(call, 1)).String())
+		}
+	}
+	return map[string]interface{}{
+		"cmp": func(call goja.FunctionCall) goja.Value {
+			return vm.ToValue(arg(call, 0).Cmp(arg(call, 1)))
+		},
+		"add": op(func(a, b *big.Int) *big.Int { return new(big.Int).Add(a, b) }),
+		"sub": op(func(a, b *big.Int) *big.Int { return new(big.Int).Sub(a, b) }),
+		"mul": op(func(a, b *big.Int) *big.Int { return new(big.Int).Mul(a, b) }),
+		"div": op(func(a, b *big.Int) *big.Int {
+			if b.Sign() == 0 {
+				panic(vm.NewTypeError("big: division by zero"))
+			}
+			return new(big.Int).Quo(a, b)
+		}),
+	}
+}
+
+// txRequest the script view of a transaction
+func (s *Script) txRequest(chainId int64, tx *types.Transaction) map[string]interface{} {
+	request := map[string]interface{}{
+		"type":        string(txRequest),
+		"chain_id":    chainId,
+		"transaction": TxView(tx),
+	}
+	if call := s.decodeCall(tx.Input); call != nil {
+		request["call"] = call
+	}
+	return request
+}
+
+// TxView the decoded transaction, addresses are lower case and numbers decimal strings
+func TxView(tx *types.Transaction) map[string]interface{} {
+	number := func(str string) string {
+		if value, ok := ParseTxNumber(str); ok {
+			return value.String()
+		}
+		return str
+	}
+	transaction := map[string]interface{}{
+		"from":                     strings.ToLower(tx.From),
+		"to":                       strings.ToLower(tx.To),
+		"value":                    number(tx.Value),
+		"gas":                      number(tx.Gas),
+		"gas_price":                number(tx.GasPrice),
+		"max_fee_per_gas":          number(tx.MaxFeePerGas),
+		"max_priority_fee_per_gas": number(tx.MaxPriorityFeePerGas),
+		"nonce":                    number(tx.Nonce),
+		"type":                     tx.Type,
+		"data":                     strings.ToLower(tx.Input),
+		"selector":                 "",
+	}
+	if len(tx.Input) >= 10 {
+		transaction["selector"] = strings.ToLower(tx.Input[0:10])
+	}
+	if fee, ok := TxFee(tx); ok {
+		transaction["fee"] = fee.String()
+	}
+	if cost, ok := TxCost(tx); ok {
+		transaction["total_cost"] = cost.String()
+	}
+	return transaction
+}
+
+// DecodeCall decodes the calldata with the abi of the rule script, nil without one or when it doesn't decode
+func (r *Rule) DecodeCall(input string) map[string]interface{} {
+	if r.script == nil {
+		return nil
+	}
+	return r.script.decodeCall(input)
+}
+
+// decodeCall decodes the calldata with the abi of the rule, nil when it doesn't decode
+func (s *Script) decodeCall(input string) map[string]interface{} {
+	if s.abi == nil || len(input) < 10 {
+		return nil
+	}
+	data, err := hexutil.Decode(input)
+	if err != nil {
+		return nil
+	}
+	method, err := s.abi.MethodById(data[:4])
+	if err != nil {
+		return nil
+	}
+	params := make(map[string]interface{})
+	if err = method.Inputs.UnpackIntoMap(params, data[4:]); err != nil {
+		logger.Warnf("[Script %s] unpack %s params error: %s", s.name, method.Name, err)
+		return nil
+	}
+	for name, value := range params {
+		params[name] = scriptValue(value)
+	}
+	return map[string]interface{}{
+		"name":     method.Name,
+		"selector": hexutil.Encode(method.ID),
+		"params":   params,
+	}
+}
+
+// scriptValue converts a decoded abi value to JSON, integers become decimal strings
+func scriptValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return strings.ToLower(v.Hex())
+	case []byte:
+		return hexutil.Encode(v)
+	case string, bool:
+		return v
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%d", rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%d", rv.Uint())
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return "0x" + hex.EncodeToString(b)
+		}
+		fallthrough
+	case reflect.Slice:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = scriptValue(rv.Index(i).Interface())
+		}
+		return items
+	case reflect.Struct:
+		fields := make(map[string]interface{})
+		for i := 0; i < rv.NumField(); i++ {
+			name := rv.Type().Field(i).Name
+			fields[strings.ToLower(name[:1])+name[1:]] = scriptValue(rv.Field(i).Interface())
+		}
+		return fields
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func (s *Script) eip712Request(chainId int64, eip712Msg *apitypes.TypedData) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     string(eip712Request),
+		"chain_id": chainId,
+		"eip712":   eip712Msg,
+	}
+}
+
+func (s *Script) messageRequest(chainId int64, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     string(messageRequest),
+		"chain_id": chainId,
+		"message":  message,
+	}
+}
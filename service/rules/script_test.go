@@ -0,0 +1,82 @@
+package rules
+
+import (
+	"evm-signer/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scriptRule(t *testing.T, script string) *Rule {
+	t.Helper()
+	rule := &Rule{Name: "script", ChainId: 1, Conditions: &Conditions{}, Script: script}
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+func setScriptTimeout(t *testing.T, timeout string) {
+	previous := scriptConfig
+	if err := SetScriptConfig(&ScriptConfig{Timeout: timeout}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { scriptConfig = previous })
+}
+
+func TestScriptFailsClosed(t *testing.T) {
+	setScriptTimeout(t, "50ms")
+	tests := []struct {
+		name    string
+		script  string
+		allowed bool
+		err     string
+	}{
+		{"allow", `function check(request) { return request.transaction.to === "0x0000000000000000000000000000000000000001"; }`, true, ""},
+		{"deny", `function check(request) { return false; }`, false, ""},
+		{"not a boolean", `function check(request) { return "true"; }`, false, "not a boolean"},
+		{"throw", `function check(request) { throw new Error("boom"); }`, false, "boom"},
+		{"timeout", `function check(request) { while (true) {} }`, false, "time limit"},
+		{"memory heavy", `function check(request) { var a = []; while (true) { a.push("x".repeat(1024)); } }`, false, "time limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := scriptRule(t, tt.script)
+			tx := &types.Transaction{To: "0x0000000000000000000000000000000000000001", Value: "1"}
+
+			start := time.Now()
+			allowed, err := rule.script.Check(rule.script.txRequest(1, tx))
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Fatalf("check ran for %s", elapsed)
+			}
+			if allowed != tt.allowed || (tt.err == "") != (err == nil) || err != nil && !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Check() = %v, %v, want %v, %q", allowed, err, tt.allowed, tt.err)
+			}
+			if rule.IsMatch(1, tx) != tt.allowed {
+				t.Fatalf("IsMatch() = %v, want %v", !tt.allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestRuleWithoutConditions(t *testing.T) {
+	tx := &types.Transaction{To: "0x0000000000000000000000000000000000000001", Value: "1"}
+	for _, rule := range []*Rule{
+		{Name: "bare", ChainId: 1},
+		{Name: "script_only", ChainId: 1, Script: `function check(request) { return true; }`},
+	} {
+		rule.Init()
+		if rule.IsMatch(1, tx) || rule.IsMatchMessage(1, "hello") {
+			t.Errorf("%s: a rule without conditions matched", rule.Name)
+		}
+		if !HasErrors(rule.Lint(nil)) {
+			t.Errorf("%s: lint accepted a rule without conditions", rule.Name)
+		}
+	}
+
+	rule := &Rule{Name: "all", ChainId: 1, Conditions: &Conditions{}}
+	rule.Init()
+	if !rule.IsMatch(1, tx) {
+		t.Error("a rule with empty conditions didn't match")
+	}
+}
@@ -0,0 +1,145 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"strings"
+)
+
+// Decode the rule file as JSON with the positions of its paths, YAML files are converted
+func Decode(file string, data []byte) ([]byte, Positions, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return yamlToJSON(data)
+	default:
+		positions, err := LocateJSON(data)
+		return data, positions, err
+	}
+}
+
+// ParseFile the rules of a rule file, each rule keeps the file and line it is defined at
+func ParseFile(file string, data []byte) (Rules, error) {
+	data, positions, err := Decode(file, data)
+	if err != nil {
+		return nil, err
+	}
+	var rs Rules
+	if err = json.Unmarshal(data, &rs); err != nil {
+		return nil, err
+	}
+	for i, rule := range rs {
+		if rule == nil {
+			return nil, fmt.Errorf("rule[%d] is null", i)
+		}
+		rule.file, rule.index = file, i
+		if pos, ok := positions[fmt.Sprintf("[%d]", i)]; ok {
+			rule.line, rule.column = pos.Line, pos.Column
+		}
+	}
+	return rs, nil
+}
+
+// Source the file and line the rule is defined at
+func (r *Rule) Source() string {
+	if r.file == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", r.file, r.line)
+}
+
+// LintDuplicates reports rule names defined in more than one file,
+// the duplicates within a file are reported by Lint
+func LintDuplicates(rs Rules) []*Problem {
+	var problems []*Problem
+	first := make(map[string]*Rule)
+	for _, rule := range rs {
+		prev, ok := first[rule.Name]
+		if !ok {
+			first[rule.Name] = rule
+			continue
+		}
+		if prev.file != rule.file {
+			problems = append(problems, &Problem{File: rule.file, Line: rule.line, Column: rule.column,
+				Rule: rule.index, Name: rule.Name, Path: "name", Level: LevelError,
+				Msg: fmt.Sprintf("name is duplicated with the rule at %s", prev.Source())})
+		}
+	}
+	return problems
+}
+
+// yamlToJSON converts a YAML document to JSON, the positions are the YAML locations
+func yamlToJSON(data []byte) ([]byte, Positions, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	positions := make(Positions)
+	if len(doc.Content) == 0 {
+		return []byte("null"), positions, nil
+	}
+	value, err := yamlValue(doc.Content[0], "", positions)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := json.Marshal(value)
+	return out, positions, err
+}
+
+func yamlValue(node *yaml.Node, path string, positions Positions) (interface{}, error) {
+	if _, ok := positions[path]; !ok {
+		positions[path] = Position{Line: node.Line, Column: node.Column}
+	}
+	switch node.Kind {
+	case yaml.AliasNode:
+		return yamlValue(node.Alias, path, positions)
+	case yaml.SequenceNode:
+		items := make([]interface{}, len(node.Content))
+		for i, item := range node.Content {
+			value, err := yamlValue(item, fmt.Sprintf("%s[%d]", path, i), positions)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = value
+		}
+		return items, nil
+	case yaml.MappingNode:
+		fields := make(map[string]interface{})
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+			}
+			keyPath := fmt.Sprintf("%s.%s", path, key.Value)
+			positions[keyPath] = Position{Line: key.Line, Column: key.Column}
+			value, err := yamlValue(node.Content[i+1], keyPath, positions)
+			if err != nil {
+				return nil, err
+			}
+			fields[key.Value] = value
+		}
+		return fields, nil
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return nil, nil
+		case "!!bool":
+			var b bool
+			if err := node.Decode(&b); err != nil {
+				return nil, err
+			}
+			return b, nil
+		case "!!int", "!!float":
+			// kept as written, wei amounts don't fit a float
+			if json.Valid([]byte(node.Value)) {
+				return json.Number(node.Value), nil
+			}
+			return node.Value, nil
+		default:
+			// strings and timestamps, RFC3339 times decode from their text
+			return node.Value, nil
+		}
+	}
+	return nil, fmt.Errorf("line %d: unsupported YAML node", node.Line)
+}
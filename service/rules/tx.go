@@ -0,0 +1,69 @@
+package rules
+
+import (
+	"evm-signer/types"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"strings"
+)
+
+// ParseTxNumber parses a hex (0x prefixed) or decimal transaction field, an empty field is 0.
+// Negative numbers are invalid
+func ParseTxNumber(str string) (*big.Int, bool) {
+	if str == "" {
+		return new(big.Int), true
+	}
+	var n *big.Int
+	var ok bool
+	if strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X") {
+		if len(str) == 2 {
+			return new(big.Int), true
+		}
+		n, ok = new(big.Int).SetString(str[2:], 16)
+	} else {
+		n, ok = new(big.Int).SetString(str, 10)
+	}
+	if !ok || n.Sign() < 0 {
+		return nil, false
+	}
+	return n, true
+}
+
+// TxFeeCap the highest price per gas the transaction may pay, maxFeePerGas for dynamic fee transactions
+// and the later types priced like them, blob (3) and set code (4) transactions, gasPrice for the others
+func TxFeeCap(tx *types.Transaction) (*big.Int, bool) {
+	txType, ok := ParseTxNumber(tx.Type)
+	if !ok {
+		return nil, false
+	}
+	if txType.Uint64() >= ethTypes.DynamicFeeTxType {
+		return ParseTxNumber(tx.MaxFeePerGas)
+	}
+	return ParseTxNumber(tx.GasPrice)
+}
+
+// TxFee worst-case fee of the transaction: gas * fee cap
+func TxFee(tx *types.Transaction) (*big.Int, bool) {
+	gas, ok := ParseTxNumber(tx.Gas)
+	if !ok {
+		return nil, false
+	}
+	feeCap, ok := TxFeeCap(tx)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Int).Mul(gas, feeCap), true
+}
+
+// TxCost worst-case cost of the transaction: fee + value
+func TxCost(tx *types.Transaction) (*big.Int, bool) {
+	fee, ok := TxFee(tx)
+	if !ok {
+		return nil, false
+	}
+	value, ok := ParseTxNumber(tx.Value)
+	if !ok {
+		return nil, false
+	}
+	return fee.Add(fee, value), true
+}
@@ -0,0 +1,34 @@
+package rules
+
+import (
+	"evm-signer/types"
+	"testing"
+)
+
+func TestTxFeeCap(t *testing.T) {
+	tests := []struct {
+		name    string
+		tx      *types.Transaction
+		wantCap string
+		wantFee string
+	}{
+		{name: "legacy", tx: &types.Transaction{Type: "0x0", Gas: "21000", GasPrice: "100"}, wantCap: "100", wantFee: "2100000"},
+		{name: "no type", tx: &types.Transaction{Gas: "21000", GasPrice: "100"}, wantCap: "100", wantFee: "2100000"},
+		{name: "access list", tx: &types.Transaction{Type: "0x1", Gas: "21000", GasPrice: "100"}, wantCap: "100", wantFee: "2100000"},
+		{name: "dynamic fee", tx: &types.Transaction{Type: "0x2", Gas: "21000", MaxFeePerGas: "200"}, wantCap: "200", wantFee: "4200000"},
+		{name: "blob", tx: &types.Transaction{Type: "0x3", Gas: "21000", MaxFeePerGas: "300"}, wantCap: "300", wantFee: "6300000"},
+		{name: "set code", tx: &types.Transaction{Type: "4", Gas: "21000", MaxFeePerGas: "400", GasPrice: "1"}, wantCap: "400", wantFee: "8400000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feeCap, ok := TxFeeCap(tt.tx)
+			if !ok || feeCap.String() != tt.wantCap {
+				t.Fatalf("TxFeeCap = %v, %v, want %s", feeCap, ok, tt.wantCap)
+			}
+			fee, ok := TxFee(tt.tx)
+			if !ok || fee.String() != tt.wantFee {
+				t.Fatalf("TxFee = %v, %v, want %s", fee, ok, tt.wantFee)
+			}
+		})
+	}
+}
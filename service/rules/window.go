@@ -0,0 +1,119 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdays by their 3-letter abbreviation and their full name
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Window a recurring time window, eg. mon-fri 09:00-18:00 in Asia/Shanghai
+type Window struct {
+	Days     []string `json:"days,omitempty" mapstructure:"days"`           // mon, tue, ... sun or full names, empty means every day
+	Hours    string   `json:"hours,omitempty" mapstructure:"hours"`         // 09:00-18:00, may cross midnight, empty means all day
+	TimeZone string   `json:"time_zone,omitempty" mapstructure:"time_zone"` // IANA time zone, default UTC
+
+	loc   *time.Location
+	days  map[time.Weekday]struct{}
+	from  int // minutes of the day
+	to    int
+	err   error
+	ready bool
+}
+
+func (w *Window) Init() error {
+	w.ready = true
+	w.err = w.init()
+	return w.err
+}
+
+func (w *Window) init() error {
+	w.loc = time.UTC
+	if w.TimeZone != "" {
+		loc, err := time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid time_zone %s: %s", w.TimeZone, err)
+		}
+		w.loc = loc
+	}
+
+	w.days = make(map[time.Weekday]struct{})
+	for _, day := range w.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("invalid day %s, eg. mon, tue, wed, thu, fri, sat, sun", day)
+		}
+		w.days[weekday] = struct{}{}
+	}
+
+	w.from, w.to = 0, 24*60
+	if w.Hours == "" {
+		return nil
+	}
+	hours := strings.Split(w.Hours, "-")
+	if len(hours) != 2 {
+		return fmt.Errorf("invalid hours %s, eg. 09:00-18:00", w.Hours)
+	}
+	var err error
+	if w.from, err = parseClock(hours[0]); err != nil {
+		return err
+	}
+	if w.to, err = parseClock(hours[1]); err != nil {
+		return err
+	}
+	if w.from == w.to {
+		return fmt.Errorf("invalid hours %s, start equals end", w.Hours)
+	}
+	return nil
+}
+
+// parseClock parses 15:04 (or 24:00) into minutes of the day
+func parseClock(clock string) (int, error) {
+	clock = strings.TrimSpace(clock)
+	if clock == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s, eg. 09:00", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// IsActive whether t falls into the window, the day of a window crossing
+// midnight is the day it starts on. A window which failed Init is never active
+func (w *Window) IsActive(t time.Time) bool {
+	if !w.ready || w.err != nil {
+		return false
+	}
+
+	t = t.In(w.loc)
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if w.from < w.to {
+		if minute < w.from || minute >= w.to {
+			return false
+		}
+	} else if minute < w.to {
+		// early morning part of a window which started the day before
+		day = (day + 6) % 7
+	} else if minute < w.from {
+		return false
+	}
+
+	if len(w.days) == 0 {
+		return true
+	}
+	_, ok := w.days[day]
+	return ok
+}
@@ -0,0 +1,28 @@
+package rules
+
+import "testing"
+
+func TestWindowDays(t *testing.T) {
+	tests := []struct {
+		day     string
+		wantErr bool
+	}{
+		{"mon", false},
+		{"Monday", false},
+		{"WED", false},
+		{"saturday", false},
+		{"Monster", true},
+		{"Wednesbury", true},
+		{"sat-urday", true},
+		{"mo", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.day, func(t *testing.T) {
+			w := &Window{Days: []string{tt.day}}
+			if err := w.Init(); (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,63 +3,117 @@ package service
 import (
 	"evm-signer/pkg/logging"
 	"evm-signer/service/account"
+	"evm-signer/service/metrics"
+	"evm-signer/service/policy"
 	"evm-signer/service/rules"
+	"evm-signer/service/webhook"
 	"evm-signer/types"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"strings"
 	"sync"
+	"time"
 )
 
 var logger *logging.SugaredLogger
 
 type Service struct {
-	lock            sync.Mutex
+	lock            sync.RWMutex
 	accountsForAddr map[string]*types.Account
-	accountForIndex map[int64]*types.Account
+	accountForRef   map[string]*types.Account // by address, source:index and alias
+	loadedAccounts  int                       // the accounts with a private key
 	iAccount        account.IAccount
+	keyring         *keyring
+	allocator       *Allocator
 	chains          map[uint64]*ChainConfig
 	whitelists      map[string]struct{}
 	rules           rules.Rules
+	matcher         *rules.Matcher
+	nonces          *NonceTracker
+	limiter         *RateLimiter
+	admin           *AdminConfig
+	approvals       *ApprovalQueue
+	router          http.Handler
+	notifier        *webhook.Dispatcher
+	policy          *policy.Client
+	startedAt       time.Time
+	rulesLoadedAt   time.Time
 }
 
 func SetLogger(_logger *logging.SugaredLogger) {
 	logger = _logger
 	rules.SetLogger(logger)
 	account.SetLogger(logger)
+	webhook.SetLogger(logger)
 }
 
-func (s *Service) SetAccountMap(_accountMap map[string]*types.Account) {
-	s.accountsForAddr = _accountMap
-}
+// GetAccount the loaded account of an address, a source:index or an alias, which may sign the operation
+// on the chain. The error is ErrAccountNotFound or ErrAccountRestricted
+func (s *Service) GetAccount(ref string, chainId int64, operation string) (*types.Account, error) {
+	derived, ok := s.lookup(ref)
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
 
-func (s *Service) SetAccountListMap(_accountListMap map[int64]*types.Account) {
-	s.accountForIndex = _accountListMap
+	// looked up again under the lock, a lock of its key meanwhile drops it from the maps. A lazy index derived
+	// without registering it is in no map, hasKey tells whether its key is still unlocked
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	account, ok := s.accountForRef[strings.ToLower(ref)]
+	if !ok && derived.PriKey == nil {
+		account, ok = derived, true
+	}
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+	if s.keyring != nil {
+		if err := s.keyring.restrictionOf(account).Allows(chainId, operation); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrAccountRestricted, err)
+		}
+	}
+	if !s.hasKey(account) {
+		return nil, ErrAccountNotFound
+	}
+	s.touch(ref)
+	return account, nil
 }
 
-func (s *Service) GetAccount(accountAddr string) (account *types.Account, ok bool) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// GetAccountList the loaded account of an index of the first source
+func (s *Service) GetAccountList(index int64) (account *types.Account, ok bool) {
+	if s.keyring == nil {
+		return nil, false
+	}
+	return s.LookupAccount(accountRef(s.keyring.first, index))
+}
 
-	account, ok = s.accountsForAddr[strings.ToLower(accountAddr)]
-	if !ok {
-		return nil, ok
+// LookupAccount the loaded account of a reference without counting it as a use of its key
+func (s *Service) LookupAccount(ref string) (account *types.Account, ok bool) {
+	if account, ok = s.lookup(ref); !ok {
+		return nil, false
 	}
-	if account.PriKey == nil {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if !s.hasKey(account) {
 		return nil, false
 	}
-	return
+	return account, true
 }
 
-func (s *Service) GetAccountList(index int64) (account *types.Account, ok bool) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
-	return s.iAccount.Account().GetAccount().Index(s.accountForIndex, index)
+func (s *Service) SetNonceTracker(nonces *NonceTracker) {
+	s.nonces = nonces
+}
+
+func (s *Service) SetRateLimiter(limiter *RateLimiter) {
+	s.limiter = limiter
 }
 
 func (s *Service) SetRules(rs rules.Rules) {
 	s.rules = rs
 	s.rules.Init()
+	s.matcher = rules.Compile(rs)
+	s.rulesLoadedAt = time.Now()
+	metrics.SetRules(rs.Length(), rs.Hash())
 }
 
 // New create new monitor service
@@ -67,6 +121,7 @@ func New(iAccount account.IAccount, whitelists map[string]struct{}) (srv *Servic
 	srv = &Service{
 		iAccount:   iAccount,
 		whitelists: whitelists,
+		startedAt:  time.Now(),
 	}
 	return srv, nil
 }
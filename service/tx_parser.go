@@ -2,38 +2,40 @@ package service
 
 import (
 	"encoding/json"
+	"evm-signer/service/rules"
 	sTypes "evm-signer/types"
-	"math/big"
-	"strings"
+	"fmt"
 )
 
-func remove0xPrefix(str string) string {
-	return strings.Replace(str, "0x", "", 1)
-}
-
 func has0xPrefix(input string) bool {
 	return len(input) >= 2 && input[0] == '0' && (input[1] == 'x' || input[1] == 'X')
 }
 
-func bigIntFromStr(val string) *big.Int {
-	value := new(big.Int)
-	if has0xPrefix(val) {
-		value.SetString(remove0xPrefix(val), 16)
-	} else {
-		value.SetString(val, 10)
+// convertToHex converts the numbers of the transaction to hex, with the parser the rules use,
+// so the signed transaction has the values the rules checked
+func convertToHex(chainId string, tx *sTypes.Transaction) error {
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"type", &tx.Type},
+		{"chainId", &chainId},
+		{"nonce", &tx.Nonce},
+		{"gas", &tx.Gas},
+		{"gasPrice", &tx.GasPrice},
+		{"maxPriorityFeePerGas", &tx.MaxPriorityFeePerGas},
+		{"maxFeePerGas", &tx.MaxFeePerGas},
+		{"value", &tx.Value},
 	}
-	return value
-}
-
-func convertToHex(chainId string, tx *sTypes.Transaction) {
-	tx.Type = "0x" + bigIntFromStr(tx.Type).Text(16)
-	tx.ChainId = "0x" + bigIntFromStr(chainId).Text(16)
-	tx.Nonce = "0x" + bigIntFromStr(tx.Nonce).Text(16)
-	tx.Gas = "0x" + bigIntFromStr(tx.Gas).Text(16)
-	tx.GasPrice = "0x" + bigIntFromStr(tx.GasPrice).Text(16)
-	tx.MaxPriorityFeePerGas = "0x" + bigIntFromStr(tx.MaxPriorityFeePerGas).Text(16)
-	tx.MaxFeePerGas = "0x" + bigIntFromStr(tx.MaxFeePerGas).Text(16)
-	tx.Value = "0x" + bigIntFromStr(tx.Value).Text(16)
+	for _, field := range fields {
+		value, ok := rules.ParseTxNumber(*field.value)
+		if !ok {
+			return fmt.Errorf("%s [ %s ] is not a number", field.name, *field.value)
+		}
+		*field.value = "0x" + value.Text(16)
+	}
+	tx.ChainId = chainId
+	return nil
 }
 
 func txParse(chainId, msgTx string) (string, error) {
@@ -43,7 +45,9 @@ func txParse(chainId, msgTx string) (string, error) {
 		return "", err
 	}
 
-	convertToHex(chainId, tx)
+	if err = convertToHex(chainId, tx); err != nil {
+		return "", err
+	}
 	marshal, err := json.Marshal(tx)
 	if err != nil {
 		return "", err
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"encoding/json"
+	sTypes "evm-signer/types"
+	"testing"
+)
+
+func TestTxParse(t *testing.T) {
+	parsed, err := txParse("1", `{"type":"0X2","nonce":"0X1F","gas":"21000","maxFeePerGas":"0x3B9ACA00","value":"1"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := &sTypes.Transaction{}
+	if err = json.Unmarshal([]byte(parsed), tx); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"type":         "0x2",
+		"chainId":      "0x1",
+		"nonce":        "0x1f",
+		"gas":          "0x5208",
+		"maxFeePerGas": "0x3b9aca00",
+		"value":        "0x1",
+		"gasPrice":     "0x0",
+	}
+	got := map[string]string{
+		"type":         tx.Type,
+		"chainId":      tx.ChainId,
+		"nonce":        tx.Nonce,
+		"gas":          tx.Gas,
+		"maxFeePerGas": tx.MaxFeePerGas,
+		"value":        tx.Value,
+		"gasPrice":     tx.GasPrice,
+	}
+	for field, value := range want {
+		if got[field] != value {
+			t.Errorf("%s = %s, want %s", field, got[field], value)
+		}
+	}
+}
+
+func TestTxParseRejectsInvalidNumbers(t *testing.T) {
+	for _, tx := range []string{`{"nonce":"0xzz"}`, `{"value":"1.5"}`, `{"gas":"-"}`,
+		`{"gasPrice":"-1"}`, `{"value":"0x-1"}`, `{"gas":"-21000"}`, `{"maxFeePerGas":"-0x1"}`} {
+		if _, err := txParse("1", tx); err == nil {
+			t.Errorf("txParse(%s) should fail", tx)
+		}
+	}
+}
+
+func TestTransactionAccessListKeys(t *testing.T) {
+	list := `[{"address":"0x000000000000000000000000000000000000dead","storageKeys":[]}]`
+	for _, key := range []string{"accessList", "access_list"} {
+		tx := &sTypes.Transaction{}
+		if err := json.Unmarshal([]byte(`{"type":"0x1","`+key+`":`+list+`}`), tx); err != nil {
+			t.Fatal(err)
+		}
+		if len(tx.AccessList) != 1 || tx.Type != "0x1" {
+			t.Errorf("%s: access list %v, type %s", key, tx.AccessList, tx.Type)
+		}
+	}
+}
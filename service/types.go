@@ -5,10 +5,30 @@ import (
 )
 
 var (
-	ErrUnSupport = fmt.Errorf("unSupport chain")
-	ErrIllegalIP = fmt.Errorf("illegal ip request")
+	ErrUnSupport         = fmt.Errorf("unSupport chain")
+	ErrIllegalIP         = fmt.Errorf("illegal ip request")
+	ErrAccountNotFound   = fmt.Errorf("account not exist")
+	ErrAccountRestricted = fmt.Errorf("account restricted")
 )
 
+// the operation kinds of the signing endpoints, an account can be restricted to some of them
+const (
+	OpTransaction = "transaction"
+	OpEIP712      = "eip712"
+	OpMessage     = "message"
+)
+
+var Operations = []string{OpTransaction, OpEIP712, OpMessage}
+
+func isOperation(operation string) bool {
+	for _, op := range Operations {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
+
 type ResponseMsg struct {
 	Code ErrCode     `json:"code"`
 	Msg  string      `json:"msg"`
@@ -30,6 +50,14 @@ const (
 	ParseError
 	ParamError
 	ForbiddenError
+	NonceError
+	RateLimited
+	PendingApproval
+	ApprovalClosed
+	PolicyDenied
+	PolicyError
+	AccountLocked
+	AccountRestricted
 )
 
 var ErrorMsgMap = map[ErrCode]string{
@@ -43,8 +71,16 @@ var ErrorMsgMap = map[ErrCode]string{
 	ExpiredRequest:     "expired request",
 	IllegalAccess:      "illegal access",
 	IllegalTransaction: "illegal transaction",
-	ParseError:        "parse error",
+	ParseError:         "parse error",
 	ParamError:         "param error",
+	NonceError:         "nonce rejected",
+	RateLimited:        "rate limited",
+	PendingApproval:    "pending approval",
+	ApprovalClosed:     "approval request closed",
+	PolicyDenied:       "policy denied",
+	PolicyError:        "policy check failed",
+	AccountLocked:      "account locked",
+	AccountRestricted:  "account restricted",
 }
 
 type MyError struct {
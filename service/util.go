@@ -1,10 +1,11 @@
 package service
 
 import (
-	"crypto/ecdsa"
-	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/crypto"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"time"
 )
 
 func (e *MyError) Error() string {
@@ -14,18 +15,26 @@ func (e *MyError) Error() string {
 	return ErrorMsgMap[e.Code]
 }
 
-func priKey2Str(priKey *ecdsa.PrivateKey) string {
-	privateKeyBytes := crypto.FromECDSA(priKey)
-	return hexutil.Encode(privateKeyBytes)[2:]
-}
-
 func ReturnError(c *gin.Context, code ErrCode, msg string) {
+	setCode(c, code)
 	c.AbortWithStatusJSON(400, ResponseMsg{
 		Code: code,
 		Msg:  msg,
 	})
 }
 
+// ReturnRateLimited responds 429 with the seconds to wait in the Retry-After header and in data.retry_after
+func ReturnRateLimited(c *gin.Context, retryAfter time.Duration, msg string) {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	setCode(c, RateLimited)
+	c.Header("Retry-After", fmt.Sprintf("%d", seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, ResponseMsg{
+		Code: RateLimited,
+		Msg:  msg,
+		Data: gin.H{"retry_after": seconds},
+	})
+}
+
 func ReturnSuccess(c *gin.Context, data interface{}) {
 	c.AbortWithStatusJSON(200, ResponseMsg{
 		Code: 0,
@@ -0,0 +1,451 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"evm-signer/pkg/jsonfile"
+	"evm-signer/pkg/logging"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	SignSuccess      EventType = "sign.success"
+	SignDenied       EventType = "sign.denied"
+	AuthFailed       EventType = "auth.failed"
+	ApprovalCreated  EventType = "approval.created"
+	ApprovalApproved EventType = "approval.approved"
+	ApprovalRejected EventType = "approval.rejected"
+	ApprovalSigned   EventType = "approval.signed"
+	ApprovalFailed   EventType = "approval.failed"
+	ApprovalExpired  EventType = "approval.expired"
+	KeyUnlocked      EventType = "key.unlocked"
+	KeyLocked        EventType = "key.locked"
+	AddressAllocated EventType = "address.allocated"
+)
+
+const (
+	defaultSpoolDir    = "data/webhook"
+	defaultMaxSpool    = 1000
+	defaultTimeout     = 5 * time.Second
+	defaultMaxAttempts = 20
+	maxBackoff         = 5 * time.Minute
+	queueSize          = 1024
+	coalesceWindow     = time.Minute
+	maxCoalesced       = 10000
+
+	SignatureHeader = "X-Signer-Signature"
+	EventHeader     = "X-Signer-Event"
+	DeliveryHeader  = "X-Signer-Delivery"
+	TimestampHeader = "X-Signer-Timestamp"
+)
+
+var logger *logging.SugaredLogger
+
+func SetLogger(_logger *logging.SugaredLogger) {
+	logger = _logger
+}
+
+type Config struct {
+	SpoolDir    string      `mapstructure:"spool_dir"`    // undelivered events, default data/webhook
+	MaxSpool    int         `mapstructure:"max_spool"`    // per endpoint, the oldest deliveries are dropped beyond it, default 1000
+	MaxAttempts int         `mapstructure:"max_attempts"` // default 20
+	Endpoints   []*Endpoint `mapstructure:"endpoints"`
+}
+
+type Endpoint struct {
+	Name    string   `mapstructure:"name"`
+	URL     string   `mapstructure:"url"`
+	Secret  string   `mapstructure:"secret"`  // HMAC-SHA256 key of the signature header
+	Events  []string `mapstructure:"events"`  // subscribed event types, empty means all
+	Timeout string   `mapstructure:"timeout"` // default 5s
+
+	timeout time.Duration
+	dir     string        // the spool dir of the endpoint
+	lock    sync.Mutex    // guards the spool dir
+	wake    chan struct{} // a delivery was spooled
+}
+
+func (e *Endpoint) subscribes(eventType EventType) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, event := range e.Events {
+		if event == string(eventType) || strings.HasSuffix(event, ".*") && strings.HasPrefix(string(eventType), event[:len(event)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	ID   string      `json:"id"`
+	Type EventType   `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// delivery an event spooled for one endpoint
+type delivery struct {
+	Endpoint string          `json:"endpoint"`
+	Event    json.RawMessage `json:"event"`
+	Type     EventType       `json:"type"`
+	ID       string          `json:"id"`
+	Attempts int             `json:"attempts"`
+	NextAt   time.Time       `json:"next_at"`
+}
+
+// Dispatcher spools events to disk and posts them to the endpoints in the background,
+// so signing never waits for the spool or a receiver
+type Dispatcher struct {
+	config    *Config
+	endpoints map[string]*Endpoint
+	client    *http.Client
+	queue     chan *Event // events waiting to be spooled
+
+	lock      sync.Mutex
+	coalesced map[string]*coalesced
+}
+
+// coalesced the events of a key within the coalescing window
+type coalesced struct {
+	emittedAt  time.Time
+	suppressed int
+}
+
+func New(config *Config) (*Dispatcher, error) {
+	if config.SpoolDir == "" {
+		config.SpoolDir = defaultSpoolDir
+	}
+	if config.MaxSpool <= 0 {
+		config.MaxSpool = defaultMaxSpool
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+
+	d := &Dispatcher{
+		config:    config,
+		endpoints: make(map[string]*Endpoint),
+		client:    &http.Client{},
+		queue:     make(chan *Event, queueSize),
+		coalesced: make(map[string]*coalesced),
+	}
+	for i, endpoint := range config.Endpoints {
+		if endpoint.URL == "" {
+			return nil, fmt.Errorf("webhook endpoints[%d] url is null", i)
+		}
+		if endpoint.Name == "" {
+			endpoint.Name = fmt.Sprintf("endpoint%d", i)
+		}
+		if endpoint.Name == "." || endpoint.Name == ".." || strings.ContainsAny(endpoint.Name, `/\`) {
+			return nil, fmt.Errorf("webhook endpoint name %s can't be used as a spool dir", endpoint.Name)
+		}
+		if _, ok := d.endpoints[endpoint.Name]; ok {
+			return nil, fmt.Errorf("webhook endpoint name %s is duplicated", endpoint.Name)
+		}
+		endpoint.timeout = defaultTimeout
+		if endpoint.Timeout != "" {
+			timeout, err := time.ParseDuration(endpoint.Timeout)
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("webhook endpoint %s invalid timeout %s", endpoint.Name, endpoint.Timeout)
+			}
+			endpoint.timeout = timeout
+		}
+		endpoint.dir = filepath.Join(config.SpoolDir, endpoint.Name)
+		endpoint.wake = make(chan struct{}, 1)
+		if err := os.MkdirAll(endpoint.dir, 0700); err != nil {
+			return nil, fmt.Errorf("create webhook spool dir error: %s", err)
+		}
+		d.endpoints[endpoint.Name] = endpoint
+	}
+	return d, nil
+}
+
+// Emit queues the event for the subscribed endpoints, a nil dispatcher drops it.
+// When the queue is full an auth.failed event is dropped and any other event is spooled right away
+func (d *Dispatcher) Emit(eventType EventType, data interface{}) {
+	if d == nil || len(d.endpoints) == 0 {
+		return
+	}
+
+	event := &Event{
+		ID:   uuid.New().String(),
+		Type: eventType,
+		Time: time.Now().UTC(),
+		Data: data,
+	}
+	select {
+	case d.queue <- event:
+	default:
+		if eventType == AuthFailed {
+			logger.Warnf("[Webhook] queue is full, drop %s event", eventType)
+			return
+		}
+		d.spool(event)
+	}
+}
+
+// EmitCoalesced emits at most one event per key and coalescing window,
+// the next event of the key carries the number of events suppressed before it
+func (d *Dispatcher) EmitCoalesced(eventType EventType, key string, data map[string]interface{}) {
+	if d == nil || len(d.endpoints) == 0 {
+		return
+	}
+
+	now := time.Now()
+	d.lock.Lock()
+	key = string(eventType) + ":" + key
+	if last, ok := d.coalesced[key]; ok && now.Sub(last.emittedAt) < coalesceWindow {
+		last.suppressed++
+		d.lock.Unlock()
+		return
+	}
+	suppressed := 0
+	if last, ok := d.coalesced[key]; ok {
+		suppressed = last.suppressed
+	}
+	if len(d.coalesced) >= maxCoalesced {
+		for k, c := range d.coalesced {
+			if now.Sub(c.emittedAt) >= coalesceWindow {
+				delete(d.coalesced, k)
+			}
+		}
+	}
+	if len(d.coalesced) < maxCoalesced {
+		d.coalesced[key] = &coalesced{emittedAt: now}
+	}
+	d.lock.Unlock()
+
+	if suppressed > 0 {
+		data["suppressed"] = suppressed
+	}
+	d.Emit(eventType, data)
+}
+
+// spool writes the event to the spool dir of every subscribed endpoint
+func (d *Dispatcher) spool(event *Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Errorf("[Webhook] marshal %s event error: %s", event.Type, err)
+		return
+	}
+
+	for name, endpoint := range d.endpoints {
+		if !endpoint.subscribes(event.Type) {
+			continue
+		}
+		_delivery := &delivery{
+			Endpoint: name,
+			Event:    body,
+			Type:     event.Type,
+			ID:       event.ID,
+			NextAt:   event.Time,
+		}
+		file := fmt.Sprintf("%020d-%s-%s.json", event.Time.UnixNano(), event.Type, event.ID)
+		endpoint.lock.Lock()
+		if err = jsonfile.Save(filepath.Join(endpoint.dir, file), _delivery); err != nil {
+			logger.Errorf("[Webhook] spool %s event for %s error: %s", event.Type, name, err)
+		}
+		d.trim(endpoint)
+		endpoint.lock.Unlock()
+
+		select {
+		case endpoint.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// spooled the spool files of the endpoint, oldest first, its lock must be held
+func spooled(endpoint *Endpoint) []string {
+	files, err := filepath.Glob(filepath.Join(endpoint.dir, "*.json"))
+	if err != nil {
+		return nil
+	}
+	sort.Strings(files)
+	return files
+}
+
+// spooledType the event type in the name of a spool file
+func spooledType(file string) EventType {
+	parts := strings.SplitN(filepath.Base(file), "-", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return EventType(parts[1])
+}
+
+// trim drops the deliveries of the endpoint beyond MaxSpool, the auth.failed events go first
+// so they never push out signing events. The endpoint lock must be held
+func (d *Dispatcher) trim(endpoint *Endpoint) {
+	files := spooled(endpoint)
+	excess := len(files) - d.config.MaxSpool
+	if excess <= 0 {
+		return
+	}
+
+	drop := make([]string, 0, excess)
+	for _, file := range files {
+		if len(drop) < excess && spooledType(file) == AuthFailed {
+			drop = append(drop, file)
+		}
+	}
+	for _, file := range files {
+		if len(drop) < excess && spooledType(file) != AuthFailed {
+			drop = append(drop, file)
+		}
+	}
+	for _, file := range drop {
+		logger.Warnf("[Webhook] spool of %s is full, drop %s", endpoint.Name, filepath.Base(file))
+		os.Remove(file)
+	}
+}
+
+// Run spools the emitted events and delivers them, every endpoint on its own, until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	if d == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, endpoint := range d.endpoints {
+		wg.Add(1)
+		go func(endpoint *Endpoint) {
+			defer wg.Done()
+			d.runEndpoint(ctx, endpoint)
+		}(endpoint)
+	}
+
+	for {
+		select {
+		case event := <-d.queue:
+			d.spool(event)
+		case <-ctx.Done():
+			// spool what was emitted before the shutdown
+			for {
+				select {
+				case event := <-d.queue:
+					d.spool(event)
+				default:
+					wg.Wait()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) runEndpoint(ctx context.Context, endpoint *Endpoint) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		d.deliverDue(ctx, endpoint)
+		select {
+		case <-ctx.Done():
+			return
+		case <-endpoint.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context, endpoint *Endpoint) {
+	endpoint.lock.Lock()
+	files := spooled(endpoint)
+	endpoint.lock.Unlock()
+
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		_delivery := new(delivery)
+		if err := jsonfile.Load(file, _delivery); err != nil {
+			// trimmed in the meantime, or broken
+			if _, statErr := os.Stat(file); statErr == nil {
+				logger.Errorf("[Webhook] drop unreadable spool file %s: %s", filepath.Base(file), err)
+				os.Remove(file)
+			}
+			continue
+		}
+		if time.Now().Before(_delivery.NextAt) {
+			continue
+		}
+
+		err := d.post(ctx, endpoint, _delivery)
+		endpoint.lock.Lock()
+		if err == nil {
+			os.Remove(file)
+		} else {
+			_delivery.Attempts++
+			if _delivery.Attempts >= d.config.MaxAttempts {
+				logger.Errorf("[Webhook] give up %s event %s for %s after %d attempts: %s",
+					_delivery.Type, _delivery.ID, endpoint.Name, _delivery.Attempts, err)
+				os.Remove(file)
+			} else {
+				backoff := time.Second << uint(_delivery.Attempts-1)
+				if backoff > maxBackoff || backoff <= 0 {
+					backoff = maxBackoff
+				}
+				_delivery.NextAt = time.Now().Add(backoff)
+				logger.Warnf("[Webhook] deliver %s event %s to %s error: %s, retry in %s",
+					_delivery.Type, _delivery.ID, endpoint.Name, err, backoff)
+				if _, statErr := os.Stat(file); statErr == nil {
+					jsonfile.Save(file, _delivery)
+				}
+			}
+		}
+		endpoint.lock.Unlock()
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, endpoint *Endpoint, _delivery *delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, endpoint.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(_delivery.Event))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(_delivery.Type))
+	req.Header.Set(DeliveryHeader, _delivery.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	if endpoint.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(endpoint.Secret, timestamp, _delivery.Event))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign the hex HMAC-SHA256 of `timestamp.body`, receivers compare it with the signature header
+// and reject deliveries whose timestamp header is too old
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"evm-signer/pkg/logging"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	SetLogger(logging.GetLogger("signer", "test", &logging.LogConfig{Level: "error"}).Sugar())
+	os.Exit(m.Run())
+}
+
+func newTestDispatcher(t *testing.T, maxSpool int, endpoints ...*Endpoint) *Dispatcher {
+	d, err := New(&Config{SpoolDir: t.TempDir(), MaxSpool: maxSpool, Endpoints: endpoints})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// runTestDispatcher runs d until the test ends
+func runTestDispatcher(t *testing.T, d *Dispatcher) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestTrimKeepsSigningEvents(t *testing.T) {
+	endpoint := &Endpoint{Name: "ops", URL: "http://127.0.0.1:0"}
+	d := newTestDispatcher(t, 3, endpoint)
+
+	d.spool(&Event{ID: "a", Type: AuthFailed, Time: time.Now()})
+	for _, id := range []string{"s1", "s2", "s3"} {
+		d.spool(&Event{ID: id, Type: SignSuccess, Time: time.Now()})
+	}
+	for _, id := range []string{"b", "c"} {
+		d.spool(&Event{ID: id, Type: AuthFailed, Time: time.Now()})
+	}
+
+	files := spooled(endpoint)
+	if len(files) != 3 {
+		t.Fatalf("%d spooled deliveries, want 3", len(files))
+	}
+	for _, file := range files {
+		if spooledType(file) != SignSuccess {
+			t.Errorf("%s was kept instead of a signing event", file)
+		}
+	}
+}
+
+func TestDeliverySignature(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t, 0, &Endpoint{Name: "ops", URL: server.URL, Secret: "secret"})
+	runTestDispatcher(t, d)
+	d.Emit(SignSuccess, map[string]interface{}{"account": "0x1"})
+
+	select {
+	case r := <-received:
+		body := <-bodies
+		timestamp := r.Header.Get(TimestampHeader)
+		if timestamp == "" {
+			t.Fatal("no timestamp header")
+		}
+		if want := "sha256=" + Sign("secret", timestamp, body); r.Header.Get(SignatureHeader) != want {
+			t.Fatalf("signature = %s, want %s", r.Header.Get(SignatureHeader), want)
+		}
+		if Sign("secret", timestamp, body) == Sign("secret", "0", body) {
+			t.Fatal("the signature doesn't cover the timestamp")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+}
+
+func TestSlowEndpointDoesNotDelayOthers(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	delivered := make(chan struct{}, 10)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- struct{}{}
+	}))
+	defer fast.Close()
+
+	d := newTestDispatcher(t, 0,
+		&Endpoint{Name: "slow", URL: slow.URL, Timeout: "1m"},
+		&Endpoint{Name: "fast", URL: fast.URL})
+	runTestDispatcher(t, d)
+
+	for i := 0; i < 3; i++ {
+		d.Emit(SignSuccess, map[string]interface{}{"i": i})
+		select {
+		case <-delivered:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("event %d to the fast endpoint waited for the slow one", i)
+		}
+	}
+}
+
+func TestEmitCoalesced(t *testing.T) {
+	d := newTestDispatcher(t, 0, &Endpoint{Name: "ops", URL: "http://127.0.0.1:0"})
+	for i := 0; i < 5; i++ {
+		d.EmitCoalesced(AuthFailed, "10.0.0.1", map[string]interface{}{"client": "10.0.0.1"})
+	}
+	d.EmitCoalesced(AuthFailed, "10.0.0.2", map[string]interface{}{"client": "10.0.0.2"})
+	if len(d.queue) != 2 {
+		t.Fatalf("%d queued events, want one per client", len(d.queue))
+	}
+
+	d.coalesced[string(AuthFailed)+":10.0.0.1"].emittedAt = time.Now().Add(-coalesceWindow)
+	d.EmitCoalesced(AuthFailed, "10.0.0.1", map[string]interface{}{"client": "10.0.0.1"})
+	<-d.queue
+	<-d.queue
+	event := <-d.queue
+	body, _ := json.Marshal(event.Data)
+	if string(body) != `{"client":"10.0.0.1","suppressed":4}` {
+		t.Fatalf("event after the window = %s", body)
+	}
+}
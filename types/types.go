@@ -2,6 +2,7 @@ package types
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"math/big"
@@ -19,6 +20,11 @@ type Account struct {
 	Index   int64 // 虚拟助记词的 map id
 	Address common.Address
 	PriKey  *ecdsa.PrivateKey
+	PubKey  *ecdsa.PublicKey // of a lazy mnemonic account, which keeps no private key
+	Source  string           // account type the key was loaded from
+	Name    string           // the account source, see the accounts config
+	Path    string           // the derivation path of a mnemonic account
+	XPub    string           // the extended public key above the index element of the path, of a mnemonic account
 }
 
 type Data struct {
@@ -55,8 +61,53 @@ type FlashBotData struct {
 }
 
 type AddressMsgInfo struct {
-	ChainId int64 `json:"chain_id"`
-	Index   int64 `json:"index"`
+	ChainId int64  `json:"chain_id"`
+	Index   int64  `json:"index"`   // an index of the first account source
+	Account string `json:"account"` // or an address, source:index or alias, used instead of the index
+}
+
+type AllocateMsgInfo struct {
+	Source string `json:"source"` // an account source with allocate enabled
+}
+
+type AllocatedAddress struct {
+	Source  string `json:"source"`
+	Index   int64  `json:"index"`
+	Account string `json:"account"` // source:index
+	Address string `json:"address"`
+}
+
+type AddressInfo struct {
+	Address               string   `json:"address"`
+	Source                string   `json:"source"`
+	Index                 int64    `json:"index"`
+	Account               string   `json:"account"` // source:index
+	Aliases               []string `json:"aliases"`
+	Type                  string   `json:"type"`
+	PublicKey             string   `json:"public_key,omitempty"` // compressed, unknown while the key is locked
+	PublicKeyUncompressed string   `json:"public_key_uncompressed,omitempty"`
+	Path                  string   `json:"path,omitempty"` // the derivation path of a mnemonic account
+	XPub                  string   `json:"xpub,omitempty"` // the extended public key above the {index} element of a mnemonic account, none for a hardened {index}
+	Locked                bool     `json:"locked"`
+}
+
+type AddressPage struct {
+	Total     int            `json:"total"`
+	Page      int            `json:"page"`
+	PageSize  int            `json:"page_size"`
+	Addresses []*AddressInfo `json:"addresses"`
+}
+
+type XPubInfo struct {
+	Source   string `json:"source"`
+	Type     string `json:"type"`
+	Path     string `json:"path"`  // with the {index} placeholder
+	Index    string `json:"index"` // the index range
+	Lazy     bool   `json:"lazy"`
+	XPub     string `json:"xpub,omitempty"`      // the key above the {index} element, empty while locked or hardened
+	XPubPath string `json:"xpub_path,omitempty"` // the path of the xpub, eg. m/44'/60'/0'/0, empty for a hardened {index}
+	Locked   bool   `json:"locked"`
+	Hardened bool   `json:"hardened,omitempty"` // the {index} element is hardened, no xpub derives its addresses
 }
 
 type Sign712MsgInfo struct {
@@ -85,12 +136,28 @@ type Transaction struct {
 	MaxPriorityFeePerGas string           `json:"maxPriorityFeePerGas"` // a.k.a. maxPriorityFeePerGas
 	MaxFeePerGas         string           `json:"maxFeePerGas"`         // a.k.a. maxFeePerGas
 	Input                string           `json:"input"`
-	AccessList           types.AccessList `json:"access_list"` // accessList tx or dynamicFee tx
+	AccessList           types.AccessList `json:"accessList"` // accessList tx or dynamicFee tx
 	V                    string           `json:"v"`
 	R                    string           `json:"r"`
 	S                    string           `json:"s"`
 }
 
+// UnmarshalJSON also accepts the access list under access_list, the key of earlier versions
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	type transaction Transaction
+	tx := struct {
+		*transaction
+		LegacyAccessList types.AccessList `json:"access_list"`
+	}{transaction: (*transaction)(t)}
+	if err := json.Unmarshal(data, &tx); err != nil {
+		return err
+	}
+	if t.AccessList == nil {
+		t.AccessList = tx.LegacyAccessList
+	}
+	return nil
+}
+
 type FmtTransaction struct {
 	ChainID    *big.Int
 	Nonce      uint64